@@ -0,0 +1,133 @@
+package structTags
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// bytesEncoding is the encoding of a byte slice or array field, chosen with
+// the base64, base64url, hex and array tag options.
+type bytesEncoding int
+
+const (
+	// defaultBytes encodes byte slices as standard base64 and byte arrays as
+	// numeric arrays, as encoding/json does.
+	defaultBytes bytesEncoding = iota
+	base64Bytes
+	base64URLBytes
+	hexBytes
+	arrayBytes
+)
+
+// parseBytesEncoding returns the encoding chosen by the options of a field of
+// type t. The options are ignored unless t is a byte slice or array.
+func parseBytesEncoding(options tagOptions, t reflect.Type) bytesEncoding {
+	if !isByteSlice(t) && !isByteArray(t) {
+		return defaultBytes
+	}
+	switch {
+	case options.Contains("base64"):
+		return base64Bytes
+	case options.Contains("base64url"):
+		return base64URLBytes
+	case options.Contains("hex"):
+		return hexBytes
+	case options.Contains("array"):
+		return arrayBytes
+	}
+
+	return defaultBytes
+}
+
+// isByteSlice reports whether t is a slice of bytes, which isn't marshalled
+// element by element because its elements marshal themselves.
+func isByteSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && isByte(t.Elem())
+}
+
+// isByteArray reports whether t is an array of bytes.
+func isByteArray(t reflect.Type) bool {
+	return t.Kind() == reflect.Array && isByte(t.Elem())
+}
+
+func isByte(t reflect.Type) bool {
+	if t.Kind() != reflect.Uint8 {
+		return false
+	}
+	p := reflect.PtrTo(t)
+
+	return !p.Implements(customTagMarshalerType) && !p.Implements(jsonMarshalerType) && !p.Implements(textMarshalerType)
+}
+
+// bytesOf copies the elements of the byte slice or array v.
+func bytesOf(v reflect.Value) []byte {
+	b := make([]byte, v.Len())
+	for i := range b {
+		b[i] = byte(v.Index(i).Uint())
+	}
+
+	return b
+}
+
+// marshalBytes reports the byte slice or array v using the provided encoding.
+func (m *CustomMarshaller) marshalBytes(w *encodeState, v reflect.Value, encoding bytesEncoding) error {
+	if encoding == arrayBytes || (encoding == defaultBytes && v.Kind() == reflect.Array) {
+		return m.marshalArray(w, v)
+	}
+
+	var s string
+	switch encoding {
+	case base64URLBytes:
+		s = base64.URLEncoding.EncodeToString(bytesOf(v))
+	case hexBytes:
+		s = hex.EncodeToString(bytesOf(v))
+	default:
+		s = base64.StdEncoding.EncodeToString(bytesOf(v))
+	}
+
+	return w.format.Scalar(reflect.ValueOf(s))
+}
+
+// unmarshalBytes decodes data into the byte slice or array v using the provided
+// encoding. Numeric arrays are accepted regardless of the encoding.
+func (m *CustomMarshaller) unmarshalBytes(d *decodeState, data []byte, v reflect.Value, encoding bytesEncoding) error {
+	if data[0] != '"' {
+		return m.unmarshal(d, data, v)
+	}
+
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	var b []byte
+	switch encoding {
+	case base64URLBytes:
+		b, err = base64.URLEncoding.DecodeString(s)
+	case hexBytes:
+		b, err = hex.DecodeString(s)
+	case arrayBytes:
+		return unmarshalTypeError(data, v)
+	default:
+		b, err = base64.StdEncoding.DecodeString(s)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal string into %s: %w", v.Type(), err)
+	}
+
+	if v.Kind() == reflect.Array {
+		if len(b) != v.Len() {
+			return fmt.Errorf("cannot unmarshal %d bytes into %s", len(b), v.Type())
+		}
+	} else {
+		v.Set(reflect.MakeSlice(v.Type(), len(b), len(b)))
+	}
+	for i := range b {
+		v.Index(i).SetUint(uint64(b[i]))
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package structTags
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type bytesStruct struct {
+	BytesVar       []byte              `json:"bytesVar" custom:"bytes_var"`
+	Base64Var      []byte              `json:"base64Var" custom:"base64_var,base64"`
+	Base64URLVar   []byte              `json:"base64URLVar" custom:"base64url_var,base64url"`
+	HexVar         []byte              `json:"hexVar" custom:"hex_var,hex"`
+	ArrayVar       []byte              `json:"arrayVar" custom:"array_var,array"`
+	FixedVar       [4]byte             `json:"fixedVar" custom:"fixed_var"`
+	FixedHexVar    [2]byte             `json:"fixedHexVar" custom:"fixed_hex_var,hex"`
+	IntArrayVar    [3]int              `json:"intArrayVar" custom:"int_array_var"`
+	StructArrayVar [1]grandChildStruct `json:"structArrayVar" custom:"struct_array_var"`
+}
+
+func TestCustomMarshaller_MarshalBytes(t *testing.T) {
+	testCases := []struct {
+		Name           string
+		Input          any
+		Configure      func(m *CustomMarshaller)
+		ExpectedError  error
+		ExpectedOutput string
+	}{
+		{
+			Name: "bytes struct",
+			Input: bytesStruct{
+				BytesVar:       []byte{0xfb, 0xff},
+				Base64Var:      []byte{0xfb, 0xff},
+				Base64URLVar:   []byte{0xfb, 0xff},
+				HexVar:         []byte{0xfb, 0xff},
+				ArrayVar:       []byte{0xfb, 0xff},
+				FixedVar:       [4]byte{1, 2, 3, 4},
+				FixedHexVar:    [2]byte{0xab, 0xcd},
+				IntArrayVar:    [3]int{1, 2, 3},
+				StructArrayVar: [1]grandChildStruct{{StringVar: "str"}},
+			},
+			ExpectedError: nil,
+			ExpectedOutput: `{"bytes_var":"+/8=","base64_var":"+/8=","base64url_var":"-_8=","hex_var":"fbff","array_var":[251,255],"fixed_var":[1,2,3,4],"fixed_hex_var":"abcd","int_array_var":[1,2,3],"struct_array_var":[{"string_var":"str"}]}
+`,
+		},
+		{
+			Name:  "nil slices",
+			Input: bytesStruct{},
+			ExpectedOutput: `{"bytes_var":null,"base64_var":null,"base64url_var":null,"hex_var":null,"array_var":null,"fixed_var":[0,0,0,0],"fixed_hex_var":"0000","int_array_var":[0,0,0],"struct_array_var":[{"string_var":""}]}
+`,
+		},
+		{
+			Name:  "nil slices as empty",
+			Input: bytesStruct{},
+			Configure: func(m *CustomMarshaller) {
+				m.NilSliceAsEmpty = true
+			},
+			ExpectedOutput: `{"bytes_var":"","base64_var":"","base64url_var":"","hex_var":"","array_var":[],"fixed_var":[0,0,0,0],"fixed_hex_var":"0000","int_array_var":[0,0,0],"struct_array_var":[{"string_var":""}]}
+`,
+		},
+		{
+			Name:          "int array",
+			Input:         [4]int{1, 2, 3, 4},
+			ExpectedError: nil,
+			ExpectedOutput: `[1,2,3,4]
+`,
+		},
+		{
+			Name:          "empty array",
+			Input:         [0]string{},
+			ExpectedError: nil,
+			ExpectedOutput: `[]
+`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+			if testCase.Configure != nil {
+				testCase.Configure(m)
+			}
+			b, err := m.Marshal(testCase.Input)
+			assertError(t, testCase.ExpectedError, err)
+			assert.Equal(t, testCase.ExpectedOutput, string(b))
+		})
+	}
+}
+
+func TestCustomMarshaller_UnmarshalBytes(t *testing.T) {
+	testCases := []struct {
+		Name           string
+		Input          string
+		ExpectedError  error
+		ExpectedOutput bytesStruct
+	}{
+		{
+			Name:  "bytes struct",
+			Input: `{"bytes_var":"+/8=","base64_var":[1],"base64url_var":"-_8=","hex_var":"fbff","array_var":[251,255],"fixed_var":[1,2,3,4,5],"fixed_hex_var":"abcd","int_array_var":[1,2],"struct_array_var":[{"string_var":"str"}]}`,
+			ExpectedOutput: bytesStruct{
+				BytesVar:       []byte{0xfb, 0xff},
+				Base64Var:      []byte{1},
+				Base64URLVar:   []byte{0xfb, 0xff},
+				HexVar:         []byte{0xfb, 0xff},
+				ArrayVar:       []byte{0xfb, 0xff},
+				FixedVar:       [4]byte{1, 2, 3, 4},
+				FixedHexVar:    [2]byte{0xab, 0xcd},
+				IntArrayVar:    [3]int{1, 2, 0},
+				StructArrayVar: [1]grandChildStruct{{StringVar: "str"}},
+			},
+		},
+		{
+			Name:           "null",
+			Input:          `{"base64_var":null,"base64url_var":null,"hex_var":null,"array_var":null}`,
+			ExpectedOutput: bytesStruct{},
+		},
+		{
+			Name:          "invalid base64",
+			Input:         `{"bytes_var":"-_8="}`,
+			ExpectedError: errors.New("failed to unmarshal bytes_var: cannot unmarshal string into []uint8: illegal base64 data at input byte 0"),
+		},
+		{
+			Name:          "wrong length",
+			Input:         `{"fixed_hex_var":"abcdef"}`,
+			ExpectedError: errors.New("failed to unmarshal fixed_hex_var: cannot unmarshal 3 bytes into [2]uint8"),
+		},
+		{
+			Name:          "string into array option",
+			Input:         `{"array_var":"+/8="}`,
+			ExpectedError: errors.New("failed to unmarshal array_var: cannot unmarshal string into []uint8"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			var output bytesStruct
+			err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).Unmarshal([]byte(testCase.Input), &output)
+			assertError(t, testCase.ExpectedError, err)
+			if err == nil {
+				assert.Equal(t, testCase.ExpectedOutput, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// generator resolves struct fields the way structTags.CustomMarshaller does,
+// using the syntax of a single package instead of reflection.
+type generator struct {
+	TargetTag          string
+	IgnoreTagWithValue string
+
+	pkg   string
+	specs map[string]*ast.TypeSpec
+}
+
+// field is a struct field which the generated methods handle.
+type field struct {
+	Key     string
+	Index   []int
+	Path    []step
+	Options string
+	Tagged  bool
+}
+
+// step is a single field selection on the way from the struct to a field.
+type step struct {
+	Name string
+	// Ptr is set for embedded struct pointers, which may be nil.
+	Ptr bool
+	// Type names the struct which Ptr points to.
+	Type string
+}
+
+// embedded is a struct whose fields are promoted.
+type embedded struct {
+	Type  string
+	Index []int
+	Path  []step
+}
+
+// load parses the non-test Go files of dir, apart from the output file.
+func (g *generator) load(dir, output string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return err
+	}
+
+	g.specs = map[string]*ast.TypeSpec{}
+	fset := token.NewFileSet()
+	for _, path := range paths {
+		name := filepath.Base(path)
+		if strings.HasSuffix(name, "_test.go") || name == output {
+			continue
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		file, err := parser.ParseFile(fset, path, src, parser.SkipObjectResolution)
+		if err != nil {
+			return err
+		}
+		if g.pkg == "" {
+			g.pkg = file.Name.Name
+		} else if g.pkg != file.Name.Name {
+			return fmt.Errorf("found packages %s and %s in %s", g.pkg, file.Name.Name, dir)
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				g.specs[typeSpec.Name.Name] = typeSpec
+			}
+		}
+	}
+	if g.pkg == "" {
+		return fmt.Errorf("no Go files in %s", dir)
+	}
+
+	return nil
+}
+
+// resolve follows aliases and defined types of the package to the struct type
+// expr refers to. It returns the name of the struct type, or "" when expr is a
+// non-struct type. Types from other packages can't be resolved.
+func (g *generator) resolve(expr ast.Expr) (string, *ast.StructType, error) {
+	name := ""
+	for {
+		switch e := expr.(type) {
+		case *ast.Ident:
+			spec, ok := g.specs[e.Name]
+			if !ok {
+				// A predeclared type.
+				return "", nil, nil
+			}
+			if spec.TypeParams != nil {
+				return "", nil, fmt.Errorf("generic type %s isn't supported", e.Name)
+			}
+			// The struct is named by the first defined type along the way,
+			// as aliases don't name types.
+			if spec.Assign == token.NoPos && name == "" {
+				name = e.Name
+			}
+			expr = spec.Type
+		case *ast.StructType:
+			if name == "" {
+				return "", nil, fmt.Errorf("anonymous struct types can't be promoted")
+			}
+			return name, e, nil
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.SelectorExpr:
+			return "", nil, fmt.Errorf("type %s.%s from another package can't be resolved", e.X, e.Sel.Name)
+		default:
+			return "", nil, nil
+		}
+	}
+}
+
+// embeddedName returns the field name of an embedded field of type expr.
+func embeddedName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(e.X)
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	case *ast.IndexExpr:
+		return embeddedName(e.X)
+	}
+
+	return ""
+}
+
+// fields returns the fields of the struct type named t, following the same
+// rules as the reflection walk: the target tag names fields and the ignored
+// tag value skips them, untagged fields use their Go names, unexported fields
+// are skipped, and embedded structs are promoted, with conflicts resolved by
+// depth and tagged-ness.
+func (g *generator) fields(t string) ([]field, error) {
+	var fields []field
+
+	var current []embedded
+	next := []embedded{{Type: t}}
+	var count, nextCount map[string]int
+	visited := map[string]bool{}
+
+	for len(next) > 0 {
+		current, next = next, nil
+		count, nextCount = nextCount, map[string]int{}
+
+		for _, f := range current {
+			if visited[f.Type] {
+				continue
+			}
+			visited[f.Type] = true
+
+			_, st, err := g.resolve(ast.NewIdent(f.Type))
+			if err != nil {
+				return nil, err
+			}
+			if st == nil {
+				return nil, fmt.Errorf("%s is not a struct type", f.Type)
+			}
+
+			i := 0
+			for _, af := range st.Fields.List {
+				names := af.Names
+				anonymous := len(names) == 0
+				if anonymous {
+					names = []*ast.Ident{ast.NewIdent(embeddedName(af.Type))}
+				}
+				var tag reflect.StructTag
+				if af.Tag != nil {
+					unquoted, err := strconv.Unquote(af.Tag.Value)
+					if err != nil {
+						return nil, err
+					}
+					tag = reflect.StructTag(unquoted)
+				}
+
+				for _, ident := range names {
+					index := make([]int, len(f.Index)+1)
+					copy(index, f.Index)
+					index[len(f.Index)] = i
+					i++
+
+					tagValue := tag.Get(g.TargetTag)
+					if tagValue == g.IgnoreTagWithValue {
+						continue
+					}
+					name, options, _ := strings.Cut(tagValue, ",")
+
+					expr := af.Type
+					star, ptr := expr.(*ast.StarExpr)
+					if ptr {
+						expr = star.X
+					}
+					inline := hasOption(options, "inline")
+					var structName string
+					if (anonymous && name == "") || inline {
+						structName, _, err = g.resolve(expr)
+						if err != nil {
+							return nil, fmt.Errorf("field %s.%s: %s", f.Type, ident.Name, err.Error())
+						}
+					}
+					promoted := structName != ""
+					if !ast.IsExported(ident.Name) && !(promoted && anonymous) {
+						continue
+					}
+					path := make([]step, len(f.Path)+1)
+					copy(path, f.Path)
+
+					if promoted {
+						if ptr && !ast.IsExported(ident.Name) {
+							return nil, fmt.Errorf("field %s.%s: embedded pointers to unexported structs aren't supported", f.Type, ident.Name)
+						}
+						path[len(f.Path)] = step{
+							Name: ident.Name,
+							Ptr:  ptr,
+							Type: structName,
+						}
+						nextCount[structName]++
+						if nextCount[structName] == 1 {
+							next = append(next, embedded{
+								Type:  structName,
+								Index: index,
+								Path:  path,
+							})
+						}
+						continue
+					}
+
+					tagged := name != ""
+					if !tagged {
+						name = ident.Name
+					}
+					path[len(f.Path)] = step{Name: ident.Name}
+					fields = append(fields, field{
+						Key:     name,
+						Index:   index,
+						Path:    path,
+						Options: options,
+						Tagged:  tagged,
+					})
+					if count[f.Type] > 1 {
+						fields = append(fields, fields[len(fields)-1])
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		x := fields
+		if x[i].Key != x[j].Key {
+			return x[i].Key < x[j].Key
+		}
+		if len(x[i].Index) != len(x[j].Index) {
+			return len(x[i].Index) < len(x[j].Index)
+		}
+		if x[i].Tagged != x[j].Tagged {
+			return x[i].Tagged
+		}
+		return lessIndex(x[i].Index, x[j].Index)
+	})
+
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].Key != fields[i].Key {
+				break
+			}
+		}
+		dominant := fields[i]
+		if advance > 1 && len(dominant.Index) == len(fields[i+1].Index) && dominant.Tagged == fields[i+1].Tagged {
+			continue
+		}
+		out = append(out, dominant)
+	}
+	fields = out
+
+	sort.Slice(fields, func(i, j int) bool {
+		return lessIndex(fields[i].Index, fields[j].Index)
+	})
+
+	return fields, nil
+}
+
+func hasOption(options, option string) bool {
+	for options != "" {
+		var name string
+		name, options, _ = strings.Cut(options, ",")
+		if name == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+func lessIndex(a, b []int) bool {
+	for k := 0; k < len(a) && k < len(b); k++ {
+		if a[k] != b[k] {
+			return a[k] < b[k]
+		}
+	}
+
+	return len(a) < len(b)
+}
+
+// generate returns the formatted source of the methods of types, for the
+// package in dir.
+func (g *generator) generate(dir string, types []string, output string) ([]byte, error) {
+	err := g.load(dir, output)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by structtags-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", g.pkg)
+	fmt.Fprintf(&b, "import \"github.com/foresthoffman/structTags\"\n")
+	for _, t := range types {
+		t = strings.TrimSpace(t)
+		fields, err := g.fields(t)
+		if err != nil {
+			return nil, fmt.Errorf("type %s: %s", t, err.Error())
+		}
+		g.writeType(&b, t, fields)
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %s", err.Error())
+	}
+
+	return src, nil
+}
+
+func (g *generator) writeType(b *bytes.Buffer, t string, fields []field) {
+	schema := strings.ToLower(t[:1]) + t[1:] + "CustomTagSchema"
+
+	fmt.Fprintf(b, "\nvar %s = &structTags.GeneratedSchema{\n", schema)
+	fmt.Fprintf(b, "TargetTag: %s,\n", strconv.Quote(g.TargetTag))
+	fmt.Fprintf(b, "IgnoreTagWithValue: %s,\n", strconv.Quote(g.IgnoreTagWithValue))
+	fmt.Fprintf(b, "Fields: []structTags.GeneratedField{\n")
+	for _, f := range fields {
+		index := make([]string, len(f.Index))
+		for i, x := range f.Index {
+			index[i] = strconv.Itoa(x)
+		}
+		fmt.Fprintf(b, "{Key: %s, Index: []int{%s}", strconv.Quote(f.Key), strings.Join(index, ", "))
+		if f.Options != "" {
+			fmt.Fprintf(b, ", Options: %s", strconv.Quote(f.Options))
+		}
+		fmt.Fprintf(b, "},\n")
+	}
+	fmt.Fprintf(b, "},\n}\n")
+
+	fmt.Fprintf(b, "\n// CustomTagSchema describes the fields handled by MarshalCustom and\n// UnmarshalCustom.\n")
+	fmt.Fprintf(b, "func (%s) CustomTagSchema() *structTags.GeneratedSchema {\nreturn %s\n}\n", t, schema)
+
+	fmt.Fprintf(b, "\n// MarshalCustom writes the fields of v for a structTags.CustomMarshaller.\n")
+	fmt.Fprintf(b, "func (v *%s) MarshalCustom(e *structTags.GeneratedEncoder) error {\n", t)
+	for i, f := range fields {
+		var nilChecks []string
+		for j, s := range f.Path[:len(f.Path)-1] {
+			if s.Ptr {
+				nilChecks = append(nilChecks, selector(f.Path[:j+1])+" != nil")
+			}
+		}
+		if len(nilChecks) > 0 {
+			fmt.Fprintf(b, "if %s {\n", strings.Join(nilChecks, " && "))
+		}
+		fmt.Fprintf(b, "e.Field(%d, &%s)\n", i, selector(f.Path))
+		if len(nilChecks) > 0 {
+			fmt.Fprintf(b, "}\n")
+		}
+	}
+	fmt.Fprintf(b, "return e.Err()\n}\n")
+
+	fmt.Fprintf(b, "\n// UnmarshalCustom reads the fields of v for a structTags.CustomMarshaller.\n")
+	fmt.Fprintf(b, "func (v *%s) UnmarshalCustom(d *structTags.GeneratedDecoder) error {\n", t)
+	fmt.Fprintf(b, "for d.Next() {\nswitch d.Field() {\n")
+	for i, f := range fields {
+		fmt.Fprintf(b, "case %d:\n", i)
+		for j, s := range f.Path[:len(f.Path)-1] {
+			if s.Ptr {
+				fmt.Fprintf(b, "if %[1]s == nil {\n%[1]s = new(%[2]s)\n}\n", selector(f.Path[:j+1]), s.Type)
+			}
+		}
+		fmt.Fprintf(b, "d.Decode(&%s)\n", selector(f.Path))
+	}
+	fmt.Fprintf(b, "}\n}\nreturn d.Err()\n}\n")
+}
+
+// selector returns the expression selecting the field at path from v.
+func selector(path []step) string {
+	names := make([]string, len(path)+1)
+	names[0] = "v"
+	for i, s := range path {
+		names[i+1] = s.Name
+	}
+
+	return strings.Join(names, ".")
+}
@@ -0,0 +1,62 @@
+// Command structtags-gen generates MarshalCustom and UnmarshalCustom methods
+// for struct types, so that a structTags.CustomMarshaller configured with the
+// same target tag and ignored tag value can skip walking them through
+// reflection. The generated methods produce the same output as the
+// reflection walk, and are ignored by marshallers whose options would resolve
+// the fields differently.
+//
+// Usage:
+//
+//	structtags-gen -tag custom -type MyStruct,OtherStruct [-ignore -] [-output file] [dir]
+//
+// The command is typically run through a go:generate directive:
+//
+//	//go:generate structtags-gen -tag custom -type MyStruct
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	tag := flag.String("tag", "", "target tag key, e.g. custom")
+	ignore := flag.String("ignore", "-", "tag value which ignores a field")
+	types := flag.String("type", "", "comma-separated list of struct type names")
+	output := flag.String("output", "", "output file name; default <dir>/structtags_gen.go")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: structtags-gen -tag key -type T[,T...] [-ignore value] [-output file] [dir]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *tag == "" || *types == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	if *output == "" {
+		*output = filepath.Join(dir, "structtags_gen.go")
+	}
+
+	g := &generator{
+		TargetTag:          *tag,
+		IgnoreTagWithValue: *ignore,
+	}
+	src, err := g.generate(dir, strings.Split(*types, ","), filepath.Base(*output))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "structtags-gen: %s\n", err.Error())
+		os.Exit(1)
+	}
+	err = os.WriteFile(*output, src, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "structtags-gen: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
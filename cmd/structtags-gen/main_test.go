@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerator_Generate(t *testing.T) {
+	dir := filepath.Join("..", "..", "internal", "gentest")
+	expected, err := os.ReadFile(filepath.Join(dir, "structtags_gen.go"))
+	assert.NoError(t, err)
+
+	g := &generator{TargetTag: "custom", IgnoreTagWithValue: "-"}
+	src, err := g.generate(dir, []string{"Record", "Child", "Base", "Extra", "Quoted"}, "structtags_gen.go")
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(src), "internal/gentest is out of date, run go generate")
+}
+
+func TestGenerator_GenerateError(t *testing.T) {
+	testCases := []struct {
+		Name          string
+		Source        string
+		Type          string
+		ExpectedError string
+	}{
+		{
+			Name:          "missing type",
+			Source:        "package p\n",
+			Type:          "Missing",
+			ExpectedError: "type Missing: Missing is not a struct type",
+		},
+		{
+			Name:          "non-struct type",
+			Source:        "package p\n\ntype Name string\n",
+			Type:          "Name",
+			ExpectedError: "type Name: Name is not a struct type",
+		},
+		{
+			Name:          "embedded type from another package",
+			Source:        "package p\n\nimport \"bytes\"\n\ntype S struct {\n\tbytes.Buffer\n}\n",
+			Type:          "S",
+			ExpectedError: "type S: field S.Buffer: type bytes.Buffer from another package can't be resolved",
+		},
+		{
+			Name:          "embedded pointer to unexported struct",
+			Source:        "package p\n\ntype S struct {\n\t*inner\n}\n\ntype inner struct {\n\tName string\n}\n",
+			Type:          "S",
+			ExpectedError: "type S: field S.inner: embedded pointers to unexported structs aren't supported",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			dir := t.TempDir()
+			err := os.WriteFile(filepath.Join(dir, "p.go"), []byte(testCase.Source), 0o644)
+			assert.NoError(t, err)
+
+			g := &generator{TargetTag: "custom", IgnoreTagWithValue: "-"}
+			_, err = g.generate(dir, []string{testCase.Type}, "structtags_gen.go")
+			assert.EqualError(t, err, testCase.ExpectedError)
+		})
+	}
+}
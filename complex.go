@@ -0,0 +1,125 @@
+package structTags
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// ComplexEncoding decides how complex numbers are marshalled, since JSON has
+// no representation for them.
+type ComplexEncoding int
+
+const (
+	// RejectComplex makes marshalling complex numbers fail with an
+	// UnsupportedTypeError, as encoding/json does.
+	RejectComplex ComplexEncoding = iota
+	// ComplexAsArray marshals complex numbers as [real,imag].
+	ComplexAsArray
+	// ComplexAsObject marshals complex numbers as {"real":..,"imag":..}.
+	ComplexAsObject
+)
+
+// marshalComplex reports the complex number v using the marshaller's
+// ComplexEncoding.
+func (m *CustomMarshaller) marshalComplex(w *encodeState, v reflect.Value) error {
+	bits := v.Type().Bits() / 2
+	c := v.Complex()
+	for _, part := range []float64{real(c), imag(c)} {
+		if math.IsNaN(part) || math.IsInf(part, 0) {
+			return &UnsupportedValueError{
+				Value: v,
+				Str:   strconv.FormatComplex(c, 'g', -1, v.Type().Bits()),
+			}
+		}
+	}
+	// The parts keep the precision of the complex number.
+	re := reflect.ValueOf(real(c))
+	im := reflect.ValueOf(imag(c))
+	if bits == 32 {
+		re = reflect.ValueOf(float32(real(c)))
+		im = reflect.ValueOf(float32(imag(c)))
+	}
+
+	f := w.format
+	var err error
+	switch m.ComplexEncoding {
+	case ComplexAsArray:
+		err = f.BeginArray()
+		if err == nil {
+			err = f.Scalar(re)
+		}
+		if err == nil {
+			err = f.Scalar(im)
+		}
+		if err == nil {
+			err = f.EndArray()
+		}
+	case ComplexAsObject:
+		err = f.BeginObject()
+		if err == nil {
+			err = f.Key("real")
+		}
+		if err == nil {
+			err = f.Scalar(re)
+		}
+		if err == nil {
+			err = f.Key("imag")
+		}
+		if err == nil {
+			err = f.Scalar(im)
+		}
+		if err == nil {
+			err = f.EndObject()
+		}
+	default:
+		err = &UnsupportedTypeError{Type: v.Type()}
+	}
+
+	return err
+}
+
+// unmarshalComplex decodes data into the complex number v. Whatever the
+// ComplexEncoding, both the array and object encodings are accepted, as well
+// as plain numbers and strings in the format produced by fmt, e.g. "(1+2i)".
+func unmarshalComplex(data []byte, v reflect.Value) error {
+	bits := v.Type().Bits()
+
+	var parts struct {
+		Real float64 `json:"real"`
+		Imag float64 `json:"imag"`
+	}
+	switch data[0] {
+	case '[':
+		var array []float64
+		err := json.Unmarshal(data, &array)
+		if err != nil || len(array) != 2 {
+			return unmarshalTypeError(data, v)
+		}
+		v.SetComplex(complex(array[0], array[1]))
+		return nil
+	case '{':
+		err := json.Unmarshal(data, &parts)
+		if err != nil {
+			return unmarshalTypeError(data, v)
+		}
+		v.SetComplex(complex(parts.Real, parts.Imag))
+		return nil
+	}
+
+	s := string(data)
+	if data[0] == '"' {
+		err := json.Unmarshal(data, &s)
+		if err != nil {
+			return err
+		}
+	}
+	n, err := strconv.ParseComplex(s, bits)
+	if err != nil {
+		return unmarshalTypeError(data, v)
+	}
+	v.SetComplex(n)
+
+	return nil
+}
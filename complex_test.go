@@ -0,0 +1,120 @@
+package structTags
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+	"unsafe"
+)
+
+type complexStruct struct {
+	Complex64Var  complex64  `json:"complex64Var" custom:"complex64_var"`
+	Complex128Var complex128 `json:"complex128Var" custom:"complex128_var"`
+}
+
+type unsupportedStruct struct {
+	StringVar string         `json:"stringVar" custom:"string_var"`
+	FuncVar   func()         `json:"-" custom:"func_var"`
+	ChanVar   chan int       `json:"-" custom:"chan_var"`
+	PtrVar    unsafe.Pointer `json:"-" custom:"ptr_var"`
+}
+
+func TestCustomMarshaller_MarshalComplex(t *testing.T) {
+	testCases := []struct {
+		Name            string
+		Input           any
+		ComplexEncoding ComplexEncoding
+		ExpectedError   error
+		ExpectedOutput  string
+	}{
+		{
+			Name:          "reject",
+			Input:         complexStruct{Complex128Var: complex(1, 2)},
+			ExpectedError: errors.New("failed to marshal complex64_var: unsupported type: complex64"),
+		},
+		{
+			Name:            "array",
+			Input:           complexStruct{Complex64Var: complex(1.1, -2), Complex128Var: complex(0, 1e-7)},
+			ComplexEncoding: ComplexAsArray,
+			ExpectedOutput: `{"complex64_var":[1.1,-2],"complex128_var":[0,0.0000001]}
+`,
+		},
+		{
+			Name:            "object",
+			Input:           complexStruct{Complex64Var: complex(1.1, -2), Complex128Var: complex(0, 1e-7)},
+			ComplexEncoding: ComplexAsObject,
+			ExpectedOutput: `{"complex64_var":{"real":1.1,"imag":-2},"complex128_var":{"real":0,"imag":0.0000001}}
+`,
+		},
+		{
+			Name:            "NaN",
+			Input:           complex(math.NaN(), 1),
+			ComplexEncoding: ComplexAsArray,
+			ExpectedError:   errors.New("failed to marshal (root): unsupported value: (NaN+1i)"),
+		},
+		{
+			Name:          "func",
+			Input:         func() {},
+			ExpectedError: errors.New("failed to marshal (root): unsupported type: func()"),
+		},
+		{
+			Name:          "chan slice",
+			Input:         []chan int{nil},
+			ExpectedError: errors.New("failed to marshal [0]: unsupported type: chan int"),
+		},
+		{
+			Name:          "unsupported struct",
+			Input:         unsupportedStruct{StringVar: "str"},
+			ExpectedError: errors.New("failed to marshal func_var: unsupported type: func()"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+			m.ComplexEncoding = testCase.ComplexEncoding
+			b, err := m.Marshal(testCase.Input)
+			assertError(t, testCase.ExpectedError, err)
+			assert.Equal(t, testCase.ExpectedOutput, string(b))
+
+			if err == nil {
+				output := complexStruct{}
+				assert.NoError(t, m.Unmarshal(b, &output))
+				assert.Equal(t, testCase.Input, output)
+			}
+		})
+	}
+}
+
+func TestCustomMarshaller_UnmarshalComplex(t *testing.T) {
+	var output []complex128
+	err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).Unmarshal([]byte(`[[1,2],{"real":3,"imag":4},5,"(6+7i)"]`), &output)
+	assert.NoError(t, err)
+	assert.Equal(t, []complex128{complex(1, 2), complex(3, 4), complex(5, 0), complex(6, 7)}, output)
+
+	err = NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).Unmarshal([]byte(`[[1,2,3]]`), &output)
+	assert.EqualError(t, err, "failed to unmarshal [0]: cannot unmarshal array into complex128")
+}
+
+func TestCustomMarshaller_SkipFuncAndChanFields(t *testing.T) {
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	m.SkipFuncAndChanFields = true
+	b, err := m.Marshal(unsupportedStruct{StringVar: "str", FuncVar: func() {}, ChanVar: make(chan int)})
+	assert.EqualError(t, err, "failed to marshal ptr_var: unsupported type: unsafe.Pointer")
+	assert.Nil(t, b)
+
+	type skippedStruct struct {
+		StringVar string   `json:"stringVar" custom:"string_var"`
+		FuncVar   func()   `json:"-" custom:"func_var"`
+		ChanVar   chan int `json:"-" custom:"chan_var"`
+	}
+	b, err = m.Marshal(skippedStruct{StringVar: "str", FuncVar: func() {}, ChanVar: make(chan int)})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"string_var":"str"}
+`, string(b))
+
+	var output skippedStruct
+	assert.NoError(t, m.Unmarshal([]byte(`{"string_var":"str","func_var":1,"chan_var":2}`), &output))
+	assert.Equal(t, skippedStruct{StringVar: "str"}, output)
+}
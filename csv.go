@@ -0,0 +1,375 @@
+package structTags
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// MarshalCSV writes rows, a slice or array of structs or struct pointers, to w
+// as CSV. The header row holds the target tag names of the struct's fields,
+// and each element of rows follows as a record.
+//
+// Nested structs, and pointers to them, are flattened into dotted column
+// names, e.g. "parent.child", unless they contain themselves, in which case
+// the recursive field is kept in one column. Any other value which isn't a
+// string is written as its JSON encoding, and null values as empty cells.
+// The output is buffered, so that w receives nothing at all when rows fail to
+// marshal.
+func (m *CustomMarshaller) MarshalCSV(w io.Writer, rows interface{}) error {
+	v := reflect.ValueOf(rows)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	t, err := csvRowType(v)
+	if err != nil {
+		return err
+	}
+	columns, err := m.csvColumns(t)
+	if err != nil {
+		return err
+	}
+
+	b := getBuffer()
+	defer putBuffer(b)
+	cw := csv.NewWriter(b)
+	cw.Comma = m.csvComma()
+
+	record := make([]string, len(columns))
+	for i := range columns {
+		record[i] = columns[i].name
+	}
+	err = cw.Write(record)
+	if err != nil {
+		return err
+	}
+
+	cell := getBuffer()
+	defer putBuffer(cell)
+	s := m.newJSONState(cell)
+	for i := 0; i < v.Len(); i++ {
+		row := v.Index(i)
+		if row.Kind() == reflect.Ptr {
+			row = row.Elem()
+		}
+		s.path.pushIndex(i)
+		for j := range columns {
+			record[j], err = m.marshalCSVCell(s, row, &columns[j])
+			if err != nil {
+				return err
+			}
+		}
+		s.path.pop()
+		err = cw.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	err = cw.Error()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b.Bytes())
+
+	return err
+}
+
+// UnmarshalCSV reads CSV from r, and stores its records in the slice pointed
+// to by rows, whose elements are structs or struct pointers. Columns are
+// matched to fields by the header row, the way MarshalCSV names them, and
+// columns which don't match any field are skipped.
+//
+// Empty cells are skipped, leaving the field's zero value and any nil struct
+// pointers leading to it. Other cells are decoded as JSON, unless the field
+// holds a string, or they aren't valid JSON, in which case they're decoded as
+// JSON strings.
+func (m *CustomMarshaller) UnmarshalCSV(r io.Reader, rows interface{}) error {
+	v, err := unmarshalTarget(rows)
+	if err != nil {
+		return err
+	}
+	if v.Kind() != reflect.Slice {
+		return &UnsupportedTypeError{Type: v.Type()}
+	}
+	t, err := csvRowType(v)
+	if err != nil {
+		return err
+	}
+	columns, err := m.csvColumns(t)
+	if err != nil {
+		return err
+	}
+
+	cr := csv.NewReader(r)
+	cr.Comma = m.csvComma()
+	header, err := cr.Read()
+	if err == io.EOF {
+		v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	matched := make([]*csvColumn, len(header))
+	for i, name := range header {
+		matched[i] = matchCSVColumn(columns, name)
+	}
+
+	out := reflect.MakeSlice(v.Type(), 0, 0)
+	d := m.newDecodeState()
+	for i := 0; ; i++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		elem := reflect.New(v.Type().Elem()).Elem()
+		row := elem
+		if row.Kind() == reflect.Ptr {
+			row.Set(reflect.New(t))
+			row = row.Elem()
+		}
+		d.path.pushIndex(i)
+		for j, cell := range record {
+			if matched[j] == nil {
+				continue
+			}
+			err = m.unmarshalCSVCell(d, row, matched[j], cell)
+			if err != nil {
+				return err
+			}
+		}
+		d.path.pop()
+		out = reflect.Append(out, elem)
+	}
+	v.Set(out)
+
+	return nil
+}
+
+func (m *CustomMarshaller) csvComma() rune {
+	if m.CSVComma == 0 {
+		return ','
+	}
+
+	return m.CSVComma
+}
+
+// csvRowType returns the struct type of the elements of rows.
+func csvRowType(rows reflect.Value) (reflect.Type, error) {
+	if rows.Kind() != reflect.Slice && rows.Kind() != reflect.Array {
+		if !rows.IsValid() {
+			return nil, ErrNilObject
+		}
+		return nil, &UnsupportedTypeError{Type: rows.Type()}
+	}
+	t := rows.Type().Elem()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, &UnsupportedTypeError{Type: rows.Type()}
+	}
+
+	return t, nil
+}
+
+// csvColumn is a column of CSV rows, which holds a field of the rows' struct,
+// possibly nested inside of other struct fields.
+type csvColumn struct {
+	name string
+	// fields leads from the row to the column's value, one struct at a time.
+	fields []*fieldPlan
+}
+
+// csvColumns returns the columns of rows of the struct type t.
+func (m *CustomMarshaller) csvColumns(t reflect.Type) ([]csvColumn, error) {
+	return m.appendCSVColumns(nil, t, "", nil, map[reflect.Type]bool{t: true})
+}
+
+// appendCSVColumns appends the columns of the struct type t, which is reached
+// through parents. Structs which are already being flattened are kept in a
+// single column, rather than recursing forever.
+func (m *CustomMarshaller) appendCSVColumns(columns []csvColumn, t reflect.Type, prefix string, parents []*fieldPlan, flattening map[reflect.Type]bool) ([]csvColumn, error) {
+	plan, err := m.structPlan(t)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range plan.fields {
+		field := &plan.fields[i]
+		fields := append(parents[:len(parents):len(parents)], field)
+		name := prefix + field.TagValue
+		if nested, ok := m.csvNestedType(field); ok && !flattening[nested] {
+			flattening[nested] = true
+			columns, err = m.appendCSVColumns(columns, nested, name+".", fields, flattening)
+			if err != nil {
+				return nil, err
+			}
+			delete(flattening, nested)
+			continue
+		}
+		columns = append(columns, csvColumn{
+			name:   name,
+			fields: fields,
+		})
+	}
+
+	return columns, nil
+}
+
+// csvNestedType returns the struct type of field, when its fields are
+// flattened into columns of their own.
+func (m *CustomMarshaller) csvNestedType(field *fieldPlan) (reflect.Type, bool) {
+	if field.Quoted {
+		return nil, false
+	}
+	t := field.Type
+	if _, ok := m.encoders[t]; ok {
+		return nil, false
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if _, ok := m.encoders[t]; ok {
+		return nil, false
+	}
+	if t.Kind() != reflect.Struct || hasMarshalerMethods(t) {
+		return nil, false
+	}
+
+	return t, true
+}
+
+// matchCSVColumn finds the column named name, preferring an exact match over
+// a case-insensitive one, like matchField.
+func matchCSVColumn(columns []csvColumn, name string) *csvColumn {
+	for i := range columns {
+		if columns[i].name == name {
+			return &columns[i]
+		}
+	}
+	for i := range columns {
+		if strings.EqualFold(columns[i].name, name) {
+			return &columns[i]
+		}
+	}
+
+	return nil
+}
+
+// csvCellIsString reports whether the cells of the field hold the contents of
+// JSON strings, rather than JSON values.
+func csvCellIsString(field *fieldPlan) bool {
+	if field.Quoted {
+		return true
+	}
+	t := field.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t.Kind() == reflect.String:
+		return true
+	case isByteSlice(t):
+		return field.Bytes != arrayBytes
+	case isByteArray(t):
+		return field.Bytes != defaultBytes && field.Bytes != arrayBytes
+	}
+	pt := reflect.PtrTo(t)
+
+	return pt.Implements(textUnmarshalerType) && !pt.Implements(jsonUnmarshalerType) && !pt.Implements(customTagUnmarshalerType)
+}
+
+// marshalCSVCell returns the text of column inside of the struct row. The
+// value is marshalled as JSON, and strings are unquoted.
+func (m *CustomMarshaller) marshalCSVCell(s *encodeState, row reflect.Value, column *csvColumn) (string, error) {
+	if !row.IsValid() {
+		return "", nil
+	}
+	v := row
+	for i, field := range column.fields {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return "", nil
+			}
+			v = v.Elem()
+		}
+		var ok bool
+		v, ok = fieldByIndex(v, field.Index)
+		if !ok {
+			return "", nil
+		}
+	}
+
+	for _, field := range column.fields {
+		s.path.pushField(field.TagValue)
+	}
+	s.json.b.Reset()
+	s.json.more = false
+	field := column.fields[len(column.fields)-1]
+	err := field.encode(m, s, v)
+	if err != nil {
+		return "", s.wrap(v.Type(), err)
+	}
+	for range column.fields {
+		s.path.pop()
+	}
+
+	data := s.json.b.Bytes()
+	switch {
+	case string(data) == "null":
+		return "", nil
+	case len(data) > 0 && data[0] == '"':
+		var text string
+		err = json.Unmarshal(data, &text)
+		return text, err
+	}
+
+	return string(data), nil
+}
+
+// unmarshalCSVCell decodes cell into column inside of the struct row,
+// allocating nil struct pointers along the way.
+func (m *CustomMarshaller) unmarshalCSVCell(d *decodeState, row reflect.Value, column *csvColumn, cell string) error {
+	if cell == "" {
+		return nil
+	}
+
+	v := row
+	for i, f := range column.fields {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		d.path.pushField(f.TagValue)
+		var err error
+		v, err = fieldByIndexAlloc(v, f.Index)
+		if err != nil {
+			return d.wrap(f.Type, err)
+		}
+	}
+
+	field := column.fields[len(column.fields)-1]
+	data := []byte(cell)
+	if csvCellIsString(field) || !json.Valid(data) {
+		data, _ = json.Marshal(cell)
+	}
+	err := m.unmarshalField(d, data, v, field)
+	if err != nil {
+		return d.wrap(v.Type(), err)
+	}
+	for range column.fields {
+		d.path.pop()
+	}
+
+	return nil
+}
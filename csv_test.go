@@ -0,0 +1,240 @@
+package structTags
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type csvAddress struct {
+	City string `custom:"city"`
+	Zip  int    `custom:"zip"`
+}
+
+type csvContact struct {
+	Email   string      `custom:"email"`
+	Address *csvAddress `custom:"address"`
+}
+
+type csvRow struct {
+	ID      int        `custom:"id"`
+	Name    string     `custom:"name"`
+	Secret  string     `custom:"-"`
+	Score   *float64   `custom:"score"`
+	Tags    []string   `custom:"tags"`
+	Contact csvContact `custom:"contact"`
+	Joined  time.Time  `custom:"joined"`
+	Count   int        `custom:"count,string"`
+	Raw     []byte     `custom:"raw"`
+}
+
+type csvNode struct {
+	Name string   `custom:"name"`
+	Next *csvNode `custom:"next"`
+}
+
+func TestCustomMarshaller_MarshalCSV(t *testing.T) {
+	score := 9.5
+
+	testCases := []struct {
+		Name           string
+		Input          any
+		Configure      func(m *CustomMarshaller)
+		ExpectedError  error
+		ExpectedOutput string
+	}{
+		{
+			Name: "flattened structs",
+			Input: []csvRow{
+				{
+					ID:      1,
+					Name:    "Ada, \"the first\"",
+					Secret:  "hidden",
+					Score:   &score,
+					Tags:    []string{"a", "b"},
+					Contact: csvContact{Email: "ada@example.com", Address: &csvAddress{City: "London", Zip: 1}},
+					Joined:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+					Count:   3,
+					Raw:     []byte("hi"),
+				},
+				{ID: 2, Name: "Bob"},
+			},
+			ExpectedOutput: `id,name,score,tags,contact.email,contact.address.city,contact.address.zip,joined,count,raw
+1,"Ada, ""the first""",9.5,"[""a"",""b""]",ada@example.com,London,1,2024-01-02T03:04:05Z,3,aGk=
+2,Bob,,,,,,0001-01-01T00:00:00Z,0,
+`,
+		},
+		{
+			Name:  "TSV",
+			Input: &[]*csvAddress{{City: "Paris", Zip: 75}, nil},
+			Configure: func(m *CustomMarshaller) {
+				m.CSVComma = '\t'
+			},
+			ExpectedOutput: "city\tzip\nParis\t75\n\t\n",
+		},
+		{
+			Name:           "recursive structs",
+			Input:          []csvNode{{Name: "a", Next: &csvNode{Name: "b"}}},
+			ExpectedOutput: "name,next\na,\"{\"\"name\"\":\"\"b\"\",\"\"next\"\":null}\"\n",
+		},
+		{
+			Name:           "no rows",
+			Input:          [0]csvAddress{},
+			ExpectedOutput: "city,zip\n",
+		},
+		{
+			Name:          "non-struct rows",
+			Input:         []int{1},
+			ExpectedError: errors.New("unsupported type: []int"),
+		},
+		{
+			Name:          "nil rows",
+			Input:         nil,
+			ExpectedError: ErrNilObject,
+		},
+		{
+			Name:          "unsupported values",
+			Input:         []struct{ C chan int }{{}},
+			ExpectedError: errors.New("failed to marshal [0].C: unsupported type: chan int"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+			if testCase.Configure != nil {
+				testCase.Configure(m)
+			}
+			var b bytes.Buffer
+			err := m.MarshalCSV(&b, testCase.Input)
+			assertError(t, testCase.ExpectedError, err)
+			assert.Equal(t, testCase.ExpectedOutput, b.String())
+		})
+	}
+}
+
+func TestCustomMarshaller_MarshalCSV_RegisteredEncoder(t *testing.T) {
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	m.RegisterEncoder(reflect.TypeOf(0), func(w io.Writer, v reflect.Value) error {
+		return nil
+	})
+
+	var b bytes.Buffer
+	err := m.MarshalCSV(&b, []csvAddress{{City: "Paris", Zip: 75}})
+	assert.EqualError(t, err, "failed to marshal [0].zip: registered encoder for type int produced invalid JSON: unexpected end of JSON input")
+	assert.Empty(t, b.String())
+}
+
+func TestCustomMarshaller_UnmarshalCSV(t *testing.T) {
+	input := `ID,unknown,contact.address.zip,name,tags,score,joined,count,raw
+1,x,7,"Ada, ""the first""","[""a""]",9.5,2024-01-02T03:04:05Z,3,aGk=
+2,y,,123,,,,,
+`
+
+	var output []*csvRow
+	err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).UnmarshalCSV(strings.NewReader(input), &output)
+	assert.NoError(t, err)
+	score := 9.5
+	assert.Equal(t, []*csvRow{
+		{
+			ID:      1,
+			Name:    "Ada, \"the first\"",
+			Score:   &score,
+			Tags:    []string{"a"},
+			Contact: csvContact{Address: &csvAddress{Zip: 7}},
+			Joined:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Count:   3,
+			Raw:     []byte("hi"),
+		},
+		{ID: 2, Name: "123"},
+	}, output)
+}
+
+func TestCustomMarshaller_UnmarshalCSV_Errors(t *testing.T) {
+	testCases := []struct {
+		Name          string
+		Input         string
+		Output        any
+		ExpectedError error
+	}{
+		{
+			Name:          "type mismatch",
+			Input:         "city,zip\nParis,abc\n",
+			Output:        &[]csvAddress{},
+			ExpectedError: errors.New("failed to unmarshal [0].zip: cannot unmarshal string into int"),
+		},
+		{
+			Name:          "nested type mismatch",
+			Input:         "contact.address.zip\ntrue\n",
+			Output:        &[]csvRow{},
+			ExpectedError: errors.New("failed to unmarshal [0].contact.address.zip: cannot unmarshal bool into int"),
+		},
+		{
+			Name:          "malformed CSV",
+			Input:         "city,zip\nParis\n",
+			Output:        &[]csvAddress{},
+			ExpectedError: errors.New("record on line 2: wrong number of fields"),
+		},
+		{
+			Name:          "non-pointer",
+			Input:         "city\n",
+			Output:        []csvAddress{},
+			ExpectedError: ErrNonPointer,
+		},
+		{
+			Name:          "non-slice",
+			Input:         "city\n",
+			Output:        &csvAddress{},
+			ExpectedError: errors.New("unsupported type: structTags.csvAddress"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).UnmarshalCSV(strings.NewReader(testCase.Input), testCase.Output)
+			assertError(t, testCase.ExpectedError, err)
+		})
+	}
+}
+
+func TestCustomMarshaller_CSVRoundTrip(t *testing.T) {
+	score := -1.25
+	input := []csvRow{
+		{
+			ID:      1,
+			Name:    "line\nbreak",
+			Score:   &score,
+			Tags:    []string{"x"},
+			Contact: csvContact{Email: "e", Address: &csvAddress{City: "c", Zip: 9}},
+			Joined:  time.Date(2020, 5, 6, 7, 8, 9, 0, time.UTC),
+			Count:   -4,
+			Raw:     []byte{0, 1},
+		},
+		{ID: 2, Name: "true"},
+	}
+
+	for _, comma := range []rune{0, '\t'} {
+		m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+		m.CSVComma = comma
+		var b bytes.Buffer
+		err := m.MarshalCSV(&b, input)
+		assert.NoError(t, err)
+
+		var output []csvRow
+		err = m.UnmarshalCSV(&b, &output)
+		assert.NoError(t, err)
+		assert.Equal(t, input, output)
+	}
+}
+
+func TestCustomMarshaller_UnmarshalCSV_Empty(t *testing.T) {
+	output := []csvAddress{{City: "old"}}
+	err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).UnmarshalCSV(strings.NewReader(""), &output)
+	assert.NoError(t, err)
+	assert.Empty(t, output)
+}
@@ -0,0 +1,409 @@
+package structTags
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Unmarshal parses the JSON-encoded data and stores the result in the value
+// pointed to by obj, matching object keys to struct fields using the
+// pre-configured target tag and ignored tag values.
+func (m *CustomMarshaller) Unmarshal(data []byte, obj interface{}) error {
+	v, err := unmarshalTarget(obj)
+	if err != nil {
+		return err
+	}
+
+	// Validate the whole document up front, so that the decoding below only
+	// has to deal with type mismatches.
+	var raw json.RawMessage
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	d := m.newDecodeState()
+	err = m.unmarshal(d, raw, v)
+	if err != nil {
+		return d.wrap(v.Type(), err)
+	}
+
+	return nil
+}
+
+// unmarshalTarget returns the value pointed to by obj, which must be a non-nil
+// pointer.
+func unmarshalTarget(obj interface{}) (reflect.Value, error) {
+	if obj == nil {
+		return reflect.Value{}, ErrNilObject
+	}
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return reflect.Value{}, ErrNonPointer
+	}
+
+	return v.Elem(), nil
+}
+
+// unmarshal decodes the JSON value held by data into v.
+func (m *CustomMarshaller) unmarshal(d *decodeState, data []byte, v reflect.Value) error {
+	return m.unmarshalNext(d, &jsonReader{data: data}, v)
+}
+
+// unmarshalNext decodes the next value of r into v. Objects and arrays are
+// decoded member by member as they're read, so that the document is only
+// walked once however deeply it's nested, and members are decoded in document
+// order.
+func (m *CustomMarshaller) unmarshalNext(d *decodeState, r *jsonReader, v reflect.Value) error {
+	c := r.peek()
+	null := c == 'n'
+	k := v.Kind()
+
+	if fn, ok := m.decoders[v.Type()]; ok && !null {
+		return unmarshalRegistered(r.value(), v, fn)
+	}
+
+	if k == reflect.Ptr {
+		if null {
+			r.value()
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		err := m.unmarshalNext(d, r, v.Elem())
+		if err != nil {
+			return d.wrap(v.Type().Elem(), err)
+		}
+		return nil
+	}
+
+	// Like encoding/json, null only resets values which can be nil.
+	if null {
+		r.value()
+		if k == reflect.Interface || k == reflect.Map || k == reflect.Slice {
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+
+	if u, ok := unmarshaler(v); ok {
+		return m.unmarshalUnmarshaler(r.value(), v, u)
+	}
+
+	if k == reflect.Interface {
+		if v.NumMethod() != 0 {
+			return fmt.Errorf("cannot unmarshal %s into non-empty interface %s", describeJSON(r.value()), v.Type())
+		}
+		// Decode into the existing value when it's a non-nil pointer, otherwise
+		// fall back to the generic encoding/json representation. Objects and
+		// arrays are walked here too, so that MaxDepth applies to them.
+		if !v.IsNil() && v.Elem().Kind() == reflect.Ptr && !v.Elem().IsNil() {
+			err := m.unmarshalNext(d, r, v.Elem())
+			if err != nil {
+				return d.wrap(v.Elem().Type(), err)
+			}
+			return nil
+		}
+		var generic reflect.Value
+		switch c {
+		case '{':
+			generic = reflect.New(reflect.TypeOf(map[string]interface{}{})).Elem()
+		case '[':
+			generic = reflect.New(reflect.TypeOf([]interface{}{})).Elem()
+		default:
+			var scalar interface{}
+			err := json.Unmarshal(r.value(), &scalar)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(scalar))
+			return nil
+		}
+		err := m.unmarshalNext(d, r, generic)
+		if err != nil {
+			return err
+		}
+		v.Set(generic)
+	} else if k == reflect.Struct {
+		if c != '{' {
+			return unmarshalTypeError(r.value(), v)
+		}
+		err := d.enter()
+		if err != nil {
+			return err
+		}
+		plan, err := m.structPlan(v.Type())
+		if err != nil {
+			return err
+		}
+		r.begin()
+		if g, ok := m.generatedUnmarshaler(v, plan); ok {
+			err = m.unmarshalGenerated(d, r, plan, g)
+			if err != nil {
+				return err
+			}
+			d.leave()
+			return nil
+		}
+		// Like encoding/json, when several keys match the same field, the last
+		// one wins.
+		for r.more('}') {
+			field, ok := plan.matchField(r.key())
+			if !ok {
+				r.value()
+				continue
+			}
+			fieldValue, err := fieldByIndexAlloc(v, field.Index)
+			if err != nil {
+				return err
+			}
+			if !fieldValue.CanSet() {
+				r.value()
+				continue
+			}
+			d.path.pushField(field.TagValue)
+			err = m.unmarshalFieldNext(d, r, fieldValue, field)
+			if err != nil {
+				return d.wrap(fieldValue.Type(), err)
+			}
+			d.path.pop()
+		}
+		d.leave()
+	} else if k == reflect.Slice && isByteSlice(v.Type()) && c == '"' {
+		return m.unmarshalBytes(d, r.value(), v, base64Bytes)
+	} else if k == reflect.Array {
+		if c != '[' {
+			return unmarshalTypeError(r.value(), v)
+		}
+		err := d.enter()
+		if err != nil {
+			return err
+		}
+		// Like encoding/json, extra elements are dropped and missing elements
+		// are zeroed.
+		r.begin()
+		i := 0
+		for ; r.more(']'); i++ {
+			if i >= v.Len() {
+				r.value()
+				continue
+			}
+			d.path.pushIndex(i)
+			err = m.unmarshalNext(d, r, v.Index(i))
+			if err != nil {
+				return d.wrap(v.Type().Elem(), err)
+			}
+			d.path.pop()
+		}
+		for ; i < v.Len(); i++ {
+			v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+		}
+		d.leave()
+	} else if k == reflect.Slice {
+		if c != '[' {
+			return unmarshalTypeError(r.value(), v)
+		}
+		err := d.enter()
+		if err != nil {
+			return err
+		}
+		// The length isn't known until the closing bracket, so the slice grows
+		// as elements are read, always into freshly zeroed memory.
+		v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+		r.begin()
+		for i := 0; r.more(']'); i++ {
+			if i == v.Cap() {
+				grown := reflect.MakeSlice(v.Type(), i, 2*i+4)
+				reflect.Copy(grown, v)
+				v.Set(grown)
+			}
+			v.SetLen(i + 1)
+			d.path.pushIndex(i)
+			err = m.unmarshalNext(d, r, v.Index(i))
+			if err != nil {
+				return d.wrap(v.Type().Elem(), err)
+			}
+			d.path.pop()
+		}
+		d.leave()
+	} else if k == reflect.Map {
+		if c != '{' {
+			return unmarshalTypeError(r.value(), v)
+		}
+		if !canParseMapKey(v.Type().Key()) {
+			return &UnsupportedTypeError{Type: v.Type()}
+		}
+		err := d.enter()
+		if err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		r.begin()
+		for r.more('}') {
+			key := r.key()
+			element := reflect.New(v.Type().Elem()).Elem()
+			d.path.pushKey(key)
+			err = m.unmarshalNext(d, r, element)
+			if err != nil {
+				return d.wrap(v.Type().Elem(), err)
+			}
+			d.path.pop()
+			mapKey, err := parseMapKey(key, v.Type().Key())
+			if err != nil {
+				return err
+			}
+			v.SetMapIndex(mapKey, element)
+		}
+		d.leave()
+	} else {
+		return unmarshalScalar(r.value(), v)
+	}
+
+	return nil
+}
+
+// unmarshalScalar decodes the JSON scalar data into v.
+func unmarshalScalar(data []byte, v reflect.Value) error {
+	k := v.Kind()
+
+	if k == reflect.Int || k == reflect.Int8 || k == reflect.Int16 || k == reflect.Int32 || k == reflect.Int64 {
+		n, err := strconv.ParseInt(string(data), 10, v.Type().Bits())
+		if err != nil {
+			return unmarshalTypeError(data, v)
+		}
+		v.SetInt(n)
+	} else if k == reflect.Uint || k == reflect.Uint8 || k == reflect.Uint16 || k == reflect.Uint32 || k == reflect.Uint64 || k == reflect.Uintptr {
+		n, err := strconv.ParseUint(string(data), 10, v.Type().Bits())
+		if err != nil {
+			return unmarshalTypeError(data, v)
+		}
+		v.SetUint(n)
+	} else if k == reflect.Float32 || k == reflect.Float64 {
+		n, err := strconv.ParseFloat(string(data), v.Type().Bits())
+		if err != nil {
+			return unmarshalTypeError(data, v)
+		}
+		v.SetFloat(n)
+	} else if k == reflect.Complex64 || k == reflect.Complex128 {
+		return unmarshalComplex(data, v)
+	} else if k == reflect.String {
+		if data[0] != '"' {
+			return unmarshalTypeError(data, v)
+		}
+		var s string
+		err := json.Unmarshal(data, &s)
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	} else if k == reflect.Bool {
+		if string(data) != "true" && string(data) != "false" {
+			return unmarshalTypeError(data, v)
+		}
+		v.SetBool(string(data) == "true")
+	} else {
+		return &UnsupportedTypeError{Type: v.Type()}
+	}
+
+	return nil
+}
+
+// unmarshalField decodes data into v, the value of field, according to the
+// field's options.
+func (m *CustomMarshaller) unmarshalField(d *decodeState, data []byte, v reflect.Value, field *fieldPlan) error {
+	if field.Quoted {
+		return m.unmarshalQuoted(d, data, v)
+	}
+	if field.Bytes != defaultBytes {
+		return m.unmarshalBytes(d, data, v, field.Bytes)
+	}
+
+	return m.unmarshal(d, data, v)
+}
+
+// unmarshalFieldNext is like unmarshalField, but decodes the next value of r.
+func (m *CustomMarshaller) unmarshalFieldNext(d *decodeState, r *jsonReader, v reflect.Value, field *fieldPlan) error {
+	if field.Quoted || field.Bytes != defaultBytes {
+		return m.unmarshalField(d, r.value(), v, field)
+	}
+
+	return m.unmarshalNext(d, r, v)
+}
+
+// unmarshalQuoted decodes the JSON encoding held inside the JSON string data, for
+// fields with the string tag option.
+func (m *CustomMarshaller) unmarshalQuoted(d *decodeState, data []byte, v reflect.Value) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return m.unmarshal(d, data, v)
+	}
+	if data[0] != '"' {
+		return fmt.Errorf("cannot unmarshal %s into quoted %s", describeJSON(data), v.Type())
+	}
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	if !json.Valid([]byte(s)) {
+		return fmt.Errorf("cannot unmarshal invalid quoted value %s into %s", data, v.Type())
+	}
+
+	return m.unmarshal(d, []byte(s), v)
+}
+
+// matchField finds the field whose tag value matches key, preferring an exact
+// match over a case-insensitive one, as encoding/json does.
+func (p *structPlan) matchField(key string) (*fieldPlan, bool) {
+	i, ok := p.matchIndex(key)
+	if !ok {
+		return nil, false
+	}
+
+	return &p.fields[i], true
+}
+
+// matchIndex is like matchField, but returns the index of the field.
+func (p *structPlan) matchIndex(key string) (int, bool) {
+	i, ok := p.byName[key]
+	if ok {
+		return i, true
+	}
+	for i := range p.fields {
+		if strings.EqualFold(p.fields[i].TagValue, key) {
+			return i, true
+		}
+	}
+
+	return -1, false
+}
+
+// describeJSON names the kind of the JSON value in data, for error messages.
+func describeJSON(data []byte) string {
+	switch data[0] {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case '"':
+		return "string"
+	case 't', 'f':
+		return "bool"
+	case 'n':
+		return "null"
+	}
+
+	return "number " + string(data)
+}
+
+func unmarshalTypeError(data []byte, v reflect.Value) error {
+	return fmt.Errorf("cannot unmarshal %s into %s", describeJSON(data), v.Type())
+}
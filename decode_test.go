@@ -0,0 +1,276 @@
+package structTags
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type pointerStruct struct {
+	StringPtrVar *string         `json:"stringPtrVar" custom:"string_ptr_var"`
+	ChildPtrVar  *childStruct    `json:"childPtrVar" custom:"child_ptr_var"`
+	AnyVar       interface{}     `json:"anyVar" custom:"any_var"`
+	AnySliceVar  []interface{}   `json:"anySliceVar" custom:"any_slice_var"`
+	ChildrenVar  []*childStruct  `json:"childrenVar" custom:"children_var"`
+	ChildMapVar  map[string]*int `json:"childMapVar" custom:"child_map_var"`
+	IgnoredVar   *string         `json:"ignoredVar" custom:"-"`
+}
+
+func TestCustomMarshaller_Unmarshal(t *testing.T) {
+	str := "str"
+	one := 1
+	onePointFive := 1.5
+
+	testCases := []struct {
+		Name           string
+		Input          string
+		Target         any
+		ExpectedError  error
+		ExpectedOutput any
+	}{
+		{
+			Name:          "nil",
+			Input:         `{}`,
+			Target:        nil,
+			ExpectedError: ErrNilObject,
+		},
+		{
+			Name:          "non-pointer",
+			Input:         `{}`,
+			Target:        scalarStruct{},
+			ExpectedError: ErrNonPointer,
+		},
+		{
+			Name:   "scalar struct",
+			Input:  `{"string_var":"str","int_var":1,"int8_var":2,"int16_var":3,"int32_var":4,"int64_var":5,"uint_var":6,"uint8_var":7,"uint16_var":8,"uint32_var":9,"uint64_var":10,"float32_var":11.1,"float64_var":12.2,"bool_var":true,"-":"ignored"}`,
+			Target: &scalarStruct{},
+			ExpectedOutput: &scalarStruct{
+				StringVar:  "str",
+				IntVar:     1,
+				Int8Var:    2,
+				Int16Var:   3,
+				Int32Var:   4,
+				Int64Var:   5,
+				UintVar:    6,
+				Uint8Var:   7,
+				Uint16Var:  8,
+				Uint32Var:  9,
+				Uint64Var:  10,
+				Float32Var: 11.1,
+				Float64Var: 12.2,
+				BoolVar:    true,
+			},
+		},
+		{
+			Name:   "slice struct",
+			Input:  `{"string_slice_var":["str","ing"],"int_slice_var":[1,2],"uint8_slice_var":[13,14],"float64_slice_var":[23.3,24.4],"bool_slice_var":[true,false],"ignored_slice_var":["ignored"]}`,
+			Target: &sliceStruct{},
+			ExpectedOutput: &sliceStruct{
+				StringSliceVar:  []string{"str", "ing"},
+				IntSliceVar:     []int{1, 2},
+				Uint8SliceVar:   []uint8{13, 14},
+				Float64SliceVar: []float64{23.3, 24.4},
+				BoolSliceVar:    []bool{true, false},
+			},
+		},
+		{
+			Name:   "map struct",
+			Input:  `{"string_map_var":{"ele":"ment","str":"ing"},"int_map_var":{"a":1,"b":2},"bool_map_var":{"a":true,"b":false}}`,
+			Target: &mapStruct{},
+			ExpectedOutput: &mapStruct{
+				StringMapVar: map[string]string{"str": "ing", "ele": "ment"},
+				IntMapVar:    map[string]int{"a": 1, "b": 2},
+				BoolMapVar:   map[string]bool{"a": true, "b": false},
+			},
+		},
+		{
+			Name:   "nested struct",
+			Input:  `{"child_struct_var":{"grand_child_struct_var":{"string_var":"str"}}}`,
+			Target: &parentStruct{},
+			ExpectedOutput: &parentStruct{
+				ChildStructVar: childStruct{
+					GrandChildStructVar: grandChildStruct{
+						StringVar: "str",
+					},
+				},
+			},
+		},
+		{
+			Name:   "pointer struct",
+			Input:  `{"string_ptr_var":"str","child_ptr_var":{"grand_child_struct_var":{"STRING_VAR":"str"}},"any_var":{"a":[1,"b",true,null]},"any_slice_var":[1.5,"str"],"children_var":[{},null],"child_map_var":{"one":1,"none":null}}`,
+			Target: &pointerStruct{},
+			ExpectedOutput: &pointerStruct{
+				StringPtrVar: &str,
+				ChildPtrVar: &childStruct{
+					GrandChildStructVar: grandChildStruct{
+						StringVar: "str",
+					},
+				},
+				AnyVar:      map[string]interface{}{"a": []interface{}{float64(1), "b", true, nil}},
+				AnySliceVar: []interface{}{1.5, "str"},
+				ChildrenVar: []*childStruct{{}, nil},
+				ChildMapVar: map[string]*int{"one": &one, "none": nil},
+			},
+		},
+		{
+			Name:           "string map",
+			Input:          `{"str":"ing","ele":"ment"}`,
+			Target:         &map[string]string{},
+			ExpectedOutput: &map[string]string{"str": "ing", "ele": "ment"},
+		},
+		{
+			Name:           "int slice",
+			Input:          `[1,2]`,
+			Target:         &[]int{},
+			ExpectedOutput: &[]int{1, 2},
+		},
+		{
+			Name:           "complex",
+			Input:          `"(1+2i)"`,
+			Target:         new(complex128),
+			ExpectedOutput: func() *complex128 { c := complex(1, 2); return &c }(),
+		},
+		{
+			Name:   "option struct",
+			Input:  `{"empty_string_var":"str","zeroer_var":{"value":1},"quoted_int_var":"2","quoted_bool_var":"true","quoted_string_var":"\"str\"","quoted_float_ptr_var":"1.5","-":"dash"}`,
+			Target: &optionStruct{},
+			ExpectedOutput: &optionStruct{
+				EmptyStringVar:    "str",
+				ZeroerVar:         positiveNumber{Value: 1},
+				QuotedIntVar:      2,
+				QuotedBoolVar:     true,
+				QuotedStringVar:   "str",
+				QuotedFloatPtrVar: &onePointFive,
+				DashVar:           "dash",
+			},
+		},
+		{
+			Name:   "embedding struct",
+			Input:  `{"id_var":2,"name_var":"a","note_var":"b","LabelVar":"c","string_var":"d","named_var":{"id_var":3}}`,
+			Target: &embeddingStruct{},
+			ExpectedOutput: &embeddingStruct{
+				EmbeddedPtrStruct: &EmbeddedPtrStruct{NoteVar: "b", OtherVar: "c"},
+				IDVar:             2,
+				InlineVar:         grandChildStruct{StringVar: "d"},
+				NamedVar:          embeddedStruct{IDVar: 3},
+			},
+		},
+		{
+			Name:          "unquoted option",
+			Input:         `{"quoted_int_var":2}`,
+			Target:        &optionStruct{},
+			ExpectedError: errors.New("failed to unmarshal quoted_int_var: cannot unmarshal number 2 into quoted int"),
+		},
+		{
+			Name:          "overflow",
+			Input:         `{"int8_var":300}`,
+			Target:        &scalarStruct{},
+			ExpectedError: errors.New("failed to unmarshal int8_var: cannot unmarshal number 300 into int8"),
+		},
+		{
+			Name:          "type mismatch",
+			Input:         `{"string_var":1}`,
+			Target:        &scalarStruct{},
+			ExpectedError: errors.New("failed to unmarshal string_var: cannot unmarshal number 1 into string"),
+		},
+		{
+			Name:          "nested type mismatch",
+			Input:         `{"child_struct_var":{"grand_child_struct_var":[]}}`,
+			Target:        &parentStruct{},
+			ExpectedError: errors.New("failed to unmarshal child_struct_var.grand_child_struct_var: cannot unmarshal array into structTags.grandChildStruct"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).Unmarshal([]byte(testCase.Input), testCase.Target)
+			assertError(t, testCase.ExpectedError, err)
+			if testCase.ExpectedOutput != nil {
+				assert.Equal(t, testCase.ExpectedOutput, testCase.Target)
+			}
+		})
+	}
+}
+
+func TestCustomMarshaller_UnmarshalRoundTrip(t *testing.T) {
+	inputs := []any{
+		&scalarStruct{StringVar: "str", IntVar: -1, Uint64Var: 1 << 63, Float32Var: 11.1, BoolVar: true},
+		&sliceStruct{StringSliceVar: []string{"str", "ing"}, Int64SliceVar: []int64{9, 10}},
+		&mapStruct{Float32MapVar: map[string]float32{"a": 21.1}},
+		&parentStruct{ChildStructVar: childStruct{GrandChildStructVar: grandChildStruct{StringVar: "str"}}},
+	}
+
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	for _, input := range inputs {
+		t.Run(reflect.TypeOf(input).Elem().Name(), func(t *testing.T) {
+			b, err := m.Marshal(input)
+			assert.NoError(t, err)
+			output := reflect.New(reflect.TypeOf(input).Elem()).Interface()
+			assert.NoError(t, m.Unmarshal(b, output))
+			roundTrip, err := m.Marshal(output)
+			assert.NoError(t, err)
+			assert.Equal(t, string(b), string(roundTrip))
+		})
+	}
+}
+
+func TestCustomMarshaller_Unmarshal_DuplicateKeys(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Input    string
+		Expected string
+	}{
+		{
+			Name:     "case-insensitive key first",
+			Input:    `{"STRING_VAR":"a","string_var":"b"}`,
+			Expected: "b",
+		},
+		{
+			Name:     "exact key first",
+			Input:    `{"string_var":"b","String_Var":"a"}`,
+			Expected: "a",
+		},
+	}
+
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			// Decoding used to depend on map iteration order, so repeat it.
+			for i := 0; i < 200; i++ {
+				var output scalarStruct
+				err := m.Unmarshal([]byte(testCase.Input), &output)
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.Expected, output.StringVar)
+			}
+		})
+	}
+}
+
+func TestCustomMarshaller_Unmarshal_Whitespace(t *testing.T) {
+	input := " {\n\t\"string_slice_var\" : [ \"a\\\"]\" , \"b\" ] ,\r\n \"int_slice_var\":[ ],\"string_map_v\\u0061r\" : { \"k\" : \"v\" } } "
+
+	var output struct {
+		sliceStruct
+		StringMapVar map[string]string `custom:"string_map_var"`
+	}
+	err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).Unmarshal([]byte(input), &output)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a\"]", "b"}, output.StringSliceVar)
+	assert.Equal(t, []int{}, output.IntSliceVar)
+	assert.Equal(t, map[string]string{"k": "v"}, output.StringMapVar)
+}
+
+func TestCustomMarshaller_Unmarshal_DeepNesting(t *testing.T) {
+	depth := 9000
+	input := strings.Repeat("[", depth) + strings.Repeat("]", depth)
+
+	start := time.Now()
+	var output interface{}
+	err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).Unmarshal([]byte(input), &output)
+	assert.NoError(t, err)
+	// Each level used to re-parse everything inside of it, which took seconds.
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,153 @@
+package structTags
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+var (
+	ErrNilObject  = errors.New("object was nil")
+	ErrNonPointer = errors.New("object was not a non-nil pointer")
+	ErrUntagged   = errors.New("field has no target tag name")
+
+	ErrInvalidUTF8 = errors.New("string was not valid UTF-8")
+
+	ErrUnsupportedType  = errors.New("unsupported type")
+	ErrUnsupportedValue = errors.New("unsupported value")
+	ErrCycle            = errors.New("encountered a cycle")
+	ErrMaxDepth         = errors.New("exceeded max depth")
+)
+
+// MarshalError is returned when a value fails to marshal. Path locates the
+// value using target tag names, e.g. "parent.children[3].name".
+type MarshalError struct {
+	Path string
+	Type reflect.Type
+	Err  error
+}
+
+func (e *MarshalError) Error() string {
+	return fmt.Sprintf("failed to marshal %s: %s", e.Path, e.Err.Error())
+}
+
+func (e *MarshalError) Unwrap() error {
+	return e.Err
+}
+
+// UnmarshalError is returned when a value fails to unmarshal. Path locates the
+// value using target tag names, e.g. "parent.children[3].name".
+type UnmarshalError struct {
+	Path string
+	Type reflect.Type
+	Err  error
+}
+
+func (e *UnmarshalError) Error() string {
+	return fmt.Sprintf("failed to unmarshal %s: %s", e.Path, e.Err.Error())
+}
+
+func (e *UnmarshalError) Unwrap() error {
+	return e.Err
+}
+
+// UnsupportedTypeError is returned when marshalling or unmarshalling a type
+// which has no JSON representation.
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrUnsupportedType.Error(), e.Type)
+}
+
+func (e *UnsupportedTypeError) Is(target error) bool {
+	return target == ErrUnsupportedType
+}
+
+// UnsupportedValueError is returned when marshalling a value which has no JSON
+// representation, such as a NaN float.
+type UnsupportedValueError struct {
+	Value reflect.Value
+	Str   string
+}
+
+func (e *UnsupportedValueError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrUnsupportedValue.Error(), e.Str)
+}
+
+func (e *UnsupportedValueError) Is(target error) bool {
+	return target == ErrUnsupportedValue
+}
+
+// CycleError is returned when marshalling a value which refers back to itself
+// through pointers, maps or slices.
+type CycleError struct {
+	Type reflect.Type
+	// Start is the path where the walk first reached the repeated value.
+	Start string
+	// Path is the path where the walk reached it again.
+	Path string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("%s via %s: %s refers back to %s", ErrCycle.Error(), e.Type, e.Path, e.Start)
+}
+
+func (e *CycleError) Is(target error) bool {
+	return target == ErrCycle
+}
+
+// MaxDepthError is returned when objects and arrays are nested deeper than the
+// CustomMarshaller's MaxDepth.
+type MaxDepthError struct {
+	MaxDepth int
+	Path     string
+}
+
+func (e *MaxDepthError) Error() string {
+	return fmt.Sprintf("%s of %d at %s", ErrMaxDepth.Error(), e.MaxDepth, e.Path)
+}
+
+func (e *MaxDepthError) Is(target error) bool {
+	return target == ErrMaxDepth
+}
+
+// unsupportedFloatError describes a NaN or infinite float.
+func unsupportedFloatError(v reflect.Value) error {
+	return &UnsupportedValueError{
+		Value: v,
+		Str:   strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()),
+	}
+}
+
+// wrap attaches the current path and type t to err, unless err already carries
+// a path.
+func (s *encodeState) wrap(t reflect.Type, err error) error {
+	switch err.(type) {
+	case *MarshalError, *CycleError, *MaxDepthError:
+		return err
+	}
+
+	return &MarshalError{
+		Path: s.path.String(),
+		Type: t,
+		Err:  err,
+	}
+}
+
+// wrap attaches the current path and type t to err, unless err already carries
+// a path.
+func (d *decodeState) wrap(t reflect.Type, err error) error {
+	switch err.(type) {
+	case *UnmarshalError, *MaxDepthError:
+		return err
+	}
+
+	return &UnmarshalError{
+		Path: d.path.String(),
+		Type: t,
+		Err:  err,
+	}
+}
@@ -0,0 +1,75 @@
+package structTags
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type errorParentStruct struct {
+	ParentVar errorChildStruct `json:"parentVar" custom:"parent"`
+}
+
+type errorChildStruct struct {
+	ChildrenVar []errorLeafStruct `json:"childrenVar" custom:"children"`
+}
+
+type errorLeafStruct struct {
+	NameVar  any     `json:"nameVar" custom:"name"`
+	RatioVar float64 `json:"ratioVar" custom:"ratio,omitempty"`
+}
+
+func TestCustomMarshaller_MarshalError(t *testing.T) {
+	input := errorParentStruct{
+		ParentVar: errorChildStruct{
+			ChildrenVar: []errorLeafStruct{{}, {}, {}, {NameVar: map[float64]string{}}},
+		},
+	}
+
+	_, err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).Marshal(input)
+	assert.EqualError(t, err, "failed to marshal parent.children[3].name: unsupported type: map[float64]string")
+
+	var marshalErr *MarshalError
+	assert.True(t, errors.As(err, &marshalErr))
+	assert.Equal(t, "parent.children[3].name", marshalErr.Path)
+	assert.Equal(t, reflect.TypeOf(map[float64]string{}), marshalErr.Type)
+
+	var typeErr *UnsupportedTypeError
+	assert.True(t, errors.As(err, &typeErr))
+	assert.Equal(t, reflect.TypeOf(map[float64]string{}), typeErr.Type)
+	assert.True(t, errors.Is(err, ErrUnsupportedType))
+	assert.False(t, errors.Is(err, ErrUnsupportedValue))
+
+	input.ParentVar.ChildrenVar[3] = errorLeafStruct{RatioVar: math.Inf(-1)}
+	_, err = NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).Marshal(input)
+	assert.EqualError(t, err, "failed to marshal parent.children[3].ratio: unsupported value: -Inf")
+	assert.True(t, errors.Is(err, ErrUnsupportedValue))
+
+	_, err = NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).Marshal(math.NaN())
+	assert.EqualError(t, err, "failed to marshal (root): unsupported value: NaN")
+}
+
+func TestCustomMarshaller_UnmarshalError(t *testing.T) {
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+
+	var output errorParentStruct
+	err := m.Unmarshal([]byte(`{"PARENT":{"children":[{},{"name":1},{"ratio":"str"}]}}`), &output)
+	assert.EqualError(t, err, "failed to unmarshal parent.children[2].ratio: cannot unmarshal string into float64")
+
+	var unmarshalErr *UnmarshalError
+	assert.True(t, errors.As(err, &unmarshalErr))
+	assert.Equal(t, "parent.children[2].ratio", unmarshalErr.Path)
+	assert.Equal(t, reflect.TypeOf(float64(0)), unmarshalErr.Type)
+
+	err = m.Unmarshal([]byte(`{}`), nil)
+	assert.True(t, errors.Is(err, ErrNilObject))
+
+	err = m.Unmarshal([]byte(`{}`), &map[float64]string{})
+	assert.True(t, errors.Is(err, ErrUnsupportedType))
+
+	m.MaxDepth = 1
+	err = m.Unmarshal([]byte(`[[]]`), &[][]int{})
+	assert.True(t, errors.Is(err, ErrMaxDepth))
+}
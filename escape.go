@@ -0,0 +1,108 @@
+package structTags
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// InvalidUTF8Policy decides how strings containing invalid UTF-8 are
+// marshalled.
+type InvalidUTF8Policy int
+
+const (
+	// ReplaceInvalidUTF8 replaces each invalid byte with U+FFFD, the Unicode
+	// replacement character, as encoding/json does.
+	ReplaceInvalidUTF8 InvalidUTF8Policy = iota
+	// RejectInvalidUTF8 makes marshalling fail.
+	RejectInvalidUTF8
+)
+
+const hexDigits = "0123456789abcdef"
+
+// appendString appends s to dst as a JSON string. Quotes, backslashes and
+// control characters are always escaped. With EscapeHTML, so are <, >, &,
+// U+2028 and U+2029, and with ASCIIOnly, so is every non-ASCII character.
+func (m *CustomMarshaller) appendString(dst []byte, s string) ([]byte, error) {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && (!m.EscapeHTML || (b != '<' && b != '>' && b != '&')) {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = appendEscapedRune(dst, rune(b))
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			if m.InvalidUTF8 == RejectInvalidUTF8 {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidUTF8, s)
+			}
+			dst = append(dst, s[start:i]...)
+			dst = appendEscapedRune(dst, utf8.RuneError)
+			i += size
+			start = i
+			continue
+		}
+		if m.ASCIIOnly || (m.EscapeHTML && (r == '\u2028' || r == '\u2029')) {
+			dst = append(dst, s[start:i]...)
+			dst = appendEscapedRune(dst, r)
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	dst = append(dst, '"')
+
+	return dst, nil
+}
+
+// appendEscapedRune appends r as a \uXXXX escape sequence, using a UTF-16
+// surrogate pair for runes outside of the Basic Multilingual Plane.
+func appendEscapedRune(dst []byte, r rune) []byte {
+	if r > 0xFFFF {
+		r1, r2 := utf16.EncodeRune(r)
+		dst = appendEscapedRune(dst, r1)
+		return appendEscapedRune(dst, r2)
+	}
+
+	return append(dst, '\\', 'u', hexDigits[r>>12&0xF], hexDigits[r>>8&0xF], hexDigits[r>>4&0xF], hexDigits[r&0xF])
+}
+
+// validUTF8 returns s with invalid UTF-8 handled according to the
+// marshaller's InvalidUTF8 policy, for formats other than JSON, which can't
+// escape invalid bytes.
+func (m *CustomMarshaller) validUTF8(s string) (string, error) {
+	if utf8.ValidString(s) {
+		return s, nil
+	}
+	if m.InvalidUTF8 == RejectInvalidUTF8 {
+		return "", fmt.Errorf("%w: %q", ErrInvalidUTF8, s)
+	}
+
+	return strings.ToValidUTF8(s, "\uFFFD"), nil
+}
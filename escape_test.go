@@ -0,0 +1,114 @@
+package structTags
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"unicode/utf8"
+)
+
+type escapeStruct struct {
+	QuoteVar string            `json:"quote\"var" custom:"quote\"var"`
+	MapVar   map[string]string `json:"mapVar" custom:"map_var"`
+}
+
+func TestCustomMarshaller_EscapeString(t *testing.T) {
+	testCases := []struct {
+		Name           string
+		Input          string
+		EscapeHTML     bool
+		ASCIIOnly      bool
+		InvalidUTF8    InvalidUTF8Policy
+		ExpectedError  error
+		ExpectedOutput string
+	}{
+		{
+			Name:           "plain",
+			Input:          "str",
+			ExpectedOutput: `"str"`,
+		},
+		{
+			Name:           "quotes and backslashes",
+			Input:          `"a\b"`,
+			ExpectedOutput: `"\"a\\b\""`,
+		},
+		{
+			Name:           "control characters",
+			Input:          "\x00\a\b\f\n\r\t\x1f\x7f",
+			ExpectedOutput: `"\u0000\u0007\b\f\n\r\t\u001f` + "\x7f" + `"`,
+		},
+		{
+			Name:           "non-ASCII",
+			Input:          "h\u00e9llo \U0001f600 \u2028",
+			ExpectedOutput: "\"h\u00e9llo \U0001f600 \u2028\"",
+		},
+		{
+			Name:           "html",
+			Input:          "<a&b>",
+			ExpectedOutput: `"<a&b>"`,
+		},
+		{
+			Name:           "escape html",
+			Input:          "<a&b> \u2028\u2029",
+			EscapeHTML:     true,
+			ExpectedOutput: `"\u003ca\u0026b\u003e \u2028\u2029"`,
+		},
+		{
+			Name:           "ASCII only",
+			Input:          "h\u00e9llo \U0001f600",
+			ASCIIOnly:      true,
+			ExpectedOutput: `"h\u00e9llo \ud83d\ude00"`,
+		},
+		{
+			Name:           "replace invalid UTF-8",
+			Input:          "a\xffb\xc3",
+			InvalidUTF8:    ReplaceInvalidUTF8,
+			ExpectedOutput: `"a\ufffdb\ufffd"`,
+		},
+		{
+			Name:          "reject invalid UTF-8",
+			Input:         "a\xffb",
+			InvalidUTF8:   RejectInvalidUTF8,
+			ExpectedError: errors.New(`string was not valid UTF-8: "a\xffb"`),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+			m.EscapeHTML = testCase.EscapeHTML
+			m.ASCIIOnly = testCase.ASCIIOnly
+			m.InvalidUTF8 = testCase.InvalidUTF8
+			b, err := m.appendString(nil, testCase.Input)
+			assertError(t, testCase.ExpectedError, err)
+			assert.Equal(t, testCase.ExpectedOutput, string(b))
+
+			// Whatever the options, valid strings must survive a round trip.
+			if err == nil && utf8.ValidString(testCase.Input) {
+				var s string
+				assert.NoError(t, json.Unmarshal(b, &s))
+				assert.Equal(t, testCase.Input, s)
+			}
+		})
+	}
+}
+
+func TestCustomMarshaller_EscapeKeys(t *testing.T) {
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	m.EscapeHTML = true
+	b, err := m.Marshal(escapeStruct{
+		QuoteVar: "\x00",
+		MapVar:   map[string]string{"<key>\n": "</script>"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"quote\"var":"\u0000","map_var":{"\u003ckey\u003e\n":"\u003c/script\u003e"}}
+`, string(b))
+
+	var output escapeStruct
+	assert.NoError(t, m.Unmarshal(b, &output))
+	assert.Equal(t, escapeStruct{
+		QuoteVar: "\x00",
+		MapVar:   map[string]string{"<key>\n": "</script>"},
+	}, output)
+}
@@ -0,0 +1,254 @@
+package structTags
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// UnexportedFieldHook is called with each unexported field which is skipped,
+// along with the struct type declaring it. Returning an error fails the
+// marshalling or unmarshalling of the struct.
+type UnexportedFieldHook func(t reflect.Type, field reflect.StructField) error
+
+// fieldMetadata helps maintain the order of a temporary list of struct fields.
+type fieldMetadata struct {
+	TagValue  string
+	Index     []int
+	Type      reflect.Type
+	Tagged    bool
+	OmitEmpty bool
+	OmitZero  bool
+	Quoted    bool
+	Bytes     bytesEncoding
+	Options   tagOptions
+}
+
+// lookupTag returns the value of the first of the target and fallback tags
+// present on the field, or an empty string if none of them are.
+func (m *CustomMarshaller) lookupTag(sf reflect.StructField) string {
+	tagValue, ok := sf.Tag.Lookup(m.TargetTag)
+	if ok {
+		return tagValue
+	}
+	for _, tag := range m.FallbackTags {
+		tagValue, ok = sf.Tag.Lookup(tag)
+		if ok {
+			return tagValue
+		}
+	}
+
+	return ""
+}
+
+// structFields returns the fields of the struct type t which aren't ignored,
+// in declaration order. The same fields are used when marshalling and
+// unmarshalling.
+//
+// The target tag's value is split into a name and comma-separated options, as
+// with encoding/json. A field is only ignored when its whole tag value matches
+// the ignored tag value, so e.g. `custom:"-,"` names a field "-". Fields
+// without a name are handled according to the UntaggedFields policy.
+// Unexported fields are skipped, and returned separately. Func and
+// chan fields are skipped too, with SkipFuncAndChanFields.
+//
+// The fields of embedded structs and struct pointers without a name, and of
+// struct fields with the inline option, are promoted into t, even when the
+// embedded struct itself is unexported. Conflicting names are resolved by
+// depth and tagged-ness, following the rules of encoding/json.
+func (m *CustomMarshaller) structFields(t reflect.Type) ([]fieldMetadata, []unexportedField, error) {
+	var fields []fieldMetadata
+	var unexported []unexportedField
+
+	// Walk the embedded structs breadth-first, so that shallower fields are
+	// found first.
+	var current []fieldMetadata
+	next := []fieldMetadata{{Type: t}}
+	var count, nextCount map[reflect.Type]int
+	visited := map[reflect.Type]bool{}
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, f := range current {
+			if visited[f.Type] {
+				continue
+			}
+			visited[f.Type] = true
+
+			for i := 0; i < f.Type.NumField(); i++ {
+				sf := f.Type.Field(i)
+				tagValue := m.lookupTag(sf)
+				if tagValue == m.IgnoreTagWithValue {
+					continue
+				}
+				name, options := parseTag(tagValue)
+				index := make([]int, len(f.Index)+1)
+				copy(index, f.Index)
+				index[len(f.Index)] = i
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				promoted := ft.Kind() == reflect.Struct && ((sf.Anonymous && name == "") || options.Contains("inline"))
+				if !sf.IsExported() && !(promoted && sf.Anonymous) {
+					// Unexported fields can't be read or set through reflection,
+					// except for the exported fields of embedded structs.
+					unexported = append(unexported, unexportedField{
+						Owner: f.Type,
+						Field: sf,
+					})
+					continue
+				}
+				if m.SkipFuncAndChanFields && (sf.Type.Kind() == reflect.Func || sf.Type.Kind() == reflect.Chan) {
+					continue
+				}
+				if promoted {
+					// Record the embedded struct, so that its fields are promoted
+					// during the next pass.
+					nextCount[ft]++
+					if nextCount[ft] == 1 {
+						next = append(next, fieldMetadata{
+							TagValue: ft.Name(),
+							Index:    index,
+							Type:     ft,
+						})
+					}
+					continue
+				}
+
+				tagged := name != ""
+				if !tagged {
+					// Character data is placed by its option rather than by its
+					// name, so it's neither skipped nor rejected for lacking one.
+					text := options.Contains("chardata") || options.Contains("cdata")
+					switch {
+					case m.UntaggedFields == UseNamingStrategy:
+						name = m.FieldNaming.Apply(sf.Name)
+					case m.UntaggedFields == SkipUntagged && !text:
+						continue
+					case m.UntaggedFields == FailOnUntagged && !text:
+						return nil, nil, fmt.Errorf("%w: %s.%s", ErrUntagged, f.Type, sf.Name)
+					default:
+						name = sf.Name
+					}
+				}
+				fields = append(fields, fieldMetadata{
+					TagValue:  name,
+					Index:     index,
+					Type:      sf.Type,
+					Tagged:    tagged,
+					OmitEmpty: options.Contains("omitempty"),
+					OmitZero:  options.Contains("omitzero"),
+					Quoted:    options.Contains("string") && canQuote(sf.Type),
+					Bytes:     parseBytesEncoding(options, sf.Type),
+					Options:   options,
+				})
+				if count[f.Type] > 1 {
+					// The same struct was embedded more than once at this depth,
+					// so its fields annihilate each other. A single duplicate is
+					// enough to trigger that below.
+					fields = append(fields, fields[len(fields)-1])
+				}
+			}
+		}
+	}
+
+	// Sort by name, breaking ties by depth, then tagged-ness, then index
+	// sequence, so that the dominant field comes first for each name.
+	sort.Slice(fields, func(i, j int) bool {
+		x := fields
+		if x[i].TagValue != x[j].TagValue {
+			return x[i].TagValue < x[j].TagValue
+		}
+		if len(x[i].Index) != len(x[j].Index) {
+			return len(x[i].Index) < len(x[j].Index)
+		}
+		if x[i].Tagged != x[j].Tagged {
+			return x[i].Tagged
+		}
+		return lessIndex(x[i].Index, x[j].Index)
+	})
+
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		name := fields[i].TagValue
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].TagValue != name {
+				break
+			}
+		}
+		dominant, ok := dominantField(fields[i : i+advance])
+		if ok {
+			out = append(out, dominant)
+		}
+	}
+	fields = out
+
+	// Restore the declaration order.
+	sort.Slice(fields, func(i, j int) bool {
+		return lessIndex(fields[i].Index, fields[j].Index)
+	})
+
+	return fields, unexported, nil
+}
+
+// dominantField returns the field which wins among fields sharing a name,
+// sorted as in structFields. There's no winner when several fields have the
+// same depth and tagged-ness.
+func dominantField(fields []fieldMetadata) (fieldMetadata, bool) {
+	if len(fields) > 1 && len(fields[0].Index) == len(fields[1].Index) && fields[0].Tagged == fields[1].Tagged {
+		return fieldMetadata{}, false
+	}
+
+	return fields[0], true
+}
+
+// lessIndex compares two field index sequences.
+func lessIndex(a, b []int) bool {
+	for k := 0; k < len(a) && k < len(b); k++ {
+		if a[k] != b[k] {
+			return a[k] < b[k]
+		}
+	}
+
+	return len(a) < len(b)
+}
+
+// fieldByIndex returns the field of the struct v at the index sequence. It
+// reports false when the field is only reachable through a nil embedded
+// pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v, true
+}
+
+// fieldByIndexAlloc is like fieldByIndex, but allocates nil embedded pointers
+// along the way, for unmarshalling.
+func fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("cannot set embedded pointer to unexported struct %s", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v, nil
+}
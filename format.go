@@ -0,0 +1,340 @@
+package structTags
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// Format encodes the values found by the marshaller's walk, so that the same
+// custom-tagged values can be written in formats other than JSON. The walk
+// resolves struct fields, tag options and marshaler methods, and reports
+// what's left: objects and arrays are bracketed by Begin and End calls, each
+// object value is preceded by its Key, and everything else is a Scalar.
+type Format interface {
+	BeginObject() error
+	Key(key string) error
+	EndObject() error
+	BeginArray() error
+	EndArray() error
+	// Scalar encodes v, which is either the zero Value, for null, or a value
+	// of a bool, integer, unsigned integer, float or string kind.
+	Scalar(v reflect.Value) error
+}
+
+// BinaryFormat is implemented by formats which encode binary data natively.
+// Byte slices without a bytes tag option are passed to Binary, rather than
+// being reported as base64 strings.
+type BinaryFormat interface {
+	Format
+	Binary(b []byte) error
+}
+
+// ExtensionFormat is implemented by formats which encode the types registered
+// with RegisterMsgpackExtension natively. Values of those types are passed to
+// Extension, along with the payload returned by their encoder.
+type ExtensionFormat interface {
+	Format
+	Extension(code int8, data []byte) error
+}
+
+// StructFormat is implemented by formats which lay out structs themselves,
+// rather than having them reported as objects keyed by field name. Structs
+// which don't marshal themselves are passed to Struct instead.
+type StructFormat interface {
+	Format
+	Struct(s *StructWriter) error
+}
+
+// StructField describes a field of the struct being written to a
+// StructFormat.
+type StructField struct {
+	// Name is the key of the field, from its target tag.
+	Name string
+	// Options holds the comma-separated options of the target tag.
+	Options string
+	// Omitted is set when Marshal would leave the field out, because of its
+	// omitempty or omitzero option, or because it's promoted from a nil
+	// embedded struct pointer.
+	Omitted bool
+}
+
+// StructWriter gives a StructFormat the fields of the struct being written,
+// in declaration order, and walks their values like Marshal does.
+type StructWriter struct {
+	m    *CustomMarshaller
+	w    *encodeState
+	v    reflect.Value
+	plan *structPlan
+}
+
+// NumField returns the number of fields of the struct which aren't ignored.
+func (s *StructWriter) NumField() int {
+	return len(s.plan.fields)
+}
+
+// Field describes the i-th field.
+func (s *StructWriter) Field(i int) StructField {
+	field := &s.plan.fields[i]
+	value, ok := fieldByIndex(s.v, field.Index)
+
+	return StructField{
+		Name:    field.TagValue,
+		Options: string(field.Options),
+		Omitted: !ok || (field.OmitEmpty && isEmptyValue(value)) || (field.OmitZero && isZeroValue(value)),
+	}
+}
+
+// WriteField walks the value of the i-th field, reporting it to the format
+// being written. A field promoted from a nil embedded struct pointer is
+// reported as null.
+func (s *StructWriter) WriteField(i int) error {
+	return s.writeField(s.w, i)
+}
+
+// WriteFieldTo is like WriteField, but reports the value to f, e.g. to
+// collect the text of a field which the format places elsewhere.
+func (s *StructWriter) WriteFieldTo(i int, f Format) error {
+	w := *s.w
+	w.format = f
+
+	return s.writeField(&w, i)
+}
+
+func (s *StructWriter) writeField(w *encodeState, i int) error {
+	field := &s.plan.fields[i]
+	value, ok := fieldByIndex(s.v, field.Index)
+	if !ok {
+		return w.format.Scalar(reflect.Value{})
+	}
+	w.path.pushField(field.TagValue)
+	err := field.encode(s.m, w, value)
+	if err != nil {
+		return w.wrap(value.Type(), err)
+	}
+	w.path.pop()
+
+	return nil
+}
+
+// FieldError attaches the path of the i-th field to err, an error the format
+// found with the field, like the errors of the walk.
+func (s *StructWriter) FieldError(i int, err error) error {
+	field := &s.plan.fields[i]
+	s.w.path.pushField(field.TagValue)
+	err = s.w.wrap(field.Type, err)
+	s.w.path.pop()
+
+	return err
+}
+
+// MarshalFormat walks obj like Marshal does, but reports the values to f
+// instead of writing JSON. The JSON produced by marshaler methods and
+// registered encoders is replayed to f. Formats can take over binary data,
+// extension types and structs by also implementing BinaryFormat,
+// ExtensionFormat and StructFormat.
+func (m *CustomMarshaller) MarshalFormat(f Format, obj interface{}) error {
+	s := m.newEncodeState(f)
+	err := m.marshal(s, reflect.ValueOf(obj))
+	if err != nil {
+		return s.wrap(reflect.TypeOf(obj), err)
+	}
+
+	return nil
+}
+
+// isScalarKind reports whether values of kind k are reported to formats as
+// scalars.
+func isScalarKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.String:
+		return true
+	}
+
+	return false
+}
+
+// jsonFormat is the Format of Marshal, which writes compact JSON.
+type jsonFormat struct {
+	m *CustomMarshaller
+	b *bytes.Buffer
+	// scratch is reused when formatting numbers and keys.
+	scratch [64]byte
+	// more is set once a value is written, so that the next key or array
+	// element is preceded by a comma.
+	more bool
+}
+
+func (f *jsonFormat) separate() {
+	if f.more {
+		f.b.WriteByte(',')
+	}
+}
+
+func (f *jsonFormat) BeginObject() error {
+	f.separate()
+	f.b.WriteByte('{')
+	f.more = false
+	return nil
+}
+
+func (f *jsonFormat) Key(key string) error {
+	f.separate()
+	b, err := f.m.appendString(f.scratch[:0], key)
+	if err != nil {
+		return err
+	}
+	f.b.Write(b)
+	f.b.WriteByte(':')
+	f.more = false
+	return nil
+}
+
+// rawKey writes a pre-escaped key, which includes the colon.
+func (f *jsonFormat) rawKey(key []byte) {
+	f.separate()
+	f.b.Write(key)
+	f.more = false
+}
+
+func (f *jsonFormat) EndObject() error {
+	f.b.WriteByte('}')
+	f.more = true
+	return nil
+}
+
+func (f *jsonFormat) BeginArray() error {
+	f.separate()
+	f.b.WriteByte('[')
+	f.more = false
+	return nil
+}
+
+func (f *jsonFormat) EndArray() error {
+	f.b.WriteByte(']')
+	f.more = true
+	return nil
+}
+
+func (f *jsonFormat) Scalar(v reflect.Value) error {
+	f.separate()
+	f.more = true
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		f.b.WriteString("null")
+	case reflect.Bool:
+		f.b.Write(strconv.AppendBool(f.scratch[:0], v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f.b.Write(strconv.AppendInt(f.scratch[:0], v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		f.b.Write(strconv.AppendUint(f.scratch[:0], v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		n := v.Float()
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return unsupportedFloatError(v)
+		}
+		f.b.Write(strconv.AppendFloat(f.scratch[:0], n, 'f', -1, v.Type().Bits()))
+	case reflect.String:
+		b, err := f.m.appendString(f.scratch[:0], v.String())
+		if err != nil {
+			return err
+		}
+		f.b.Write(b)
+	default:
+		return &UnsupportedTypeError{Type: v.Type()}
+	}
+
+	return nil
+}
+
+// raw writes the JSON encoding data as the next value.
+func (f *jsonFormat) raw(data []byte) {
+	f.separate()
+	f.b.Write(data)
+	f.more = true
+}
+
+// replayJSON reports the JSON encoding data to f, as if it were walked.
+// Numbers are reported as int64 when possible, then as uint64, and otherwise
+// as float64.
+func replayJSON(f Format, data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	// objects records, for each object and array being replayed, whether
+	// it's an object.
+	var objects []bool
+	expectKey := false
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if key, ok := token.(string); ok && expectKey {
+			err = f.Key(key)
+			if err != nil {
+				return err
+			}
+			expectKey = false
+			continue
+		}
+
+		switch token := token.(type) {
+		case json.Delim:
+			switch token {
+			case '{':
+				err = f.BeginObject()
+				objects = append(objects, true)
+			case '[':
+				err = f.BeginArray()
+				objects = append(objects, false)
+			case '}':
+				err = f.EndObject()
+				objects = objects[:len(objects)-1]
+			case ']':
+				err = f.EndArray()
+				objects = objects[:len(objects)-1]
+			}
+		case json.Number:
+			err = f.Scalar(numberValue(token))
+		case nil:
+			err = f.Scalar(reflect.Value{})
+		default:
+			err = f.Scalar(reflect.ValueOf(token))
+		}
+		if err != nil {
+			return err
+		}
+
+		// Objects expect a key after their opening brace and after each value.
+		expectKey = len(objects) > 0 && objects[len(objects)-1]
+	}
+}
+
+// numberValue returns the JSON number n as an int64, a uint64 or a float64,
+// whichever holds it first.
+func numberValue(n json.Number) reflect.Value {
+	i, err := strconv.ParseInt(string(n), 10, 64)
+	if err == nil {
+		return reflect.ValueOf(i)
+	}
+	u, err := strconv.ParseUint(string(n), 10, 64)
+	if err == nil {
+		return reflect.ValueOf(u)
+	}
+	f, _ := strconv.ParseFloat(string(n), 64)
+
+	return reflect.ValueOf(f)
+}
@@ -0,0 +1,213 @@
+package structTags
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recordingFormat records the calls made by the walk.
+type recordingFormat struct {
+	calls []string
+}
+
+func (f *recordingFormat) BeginObject() error {
+	f.calls = append(f.calls, "{")
+	return nil
+}
+
+func (f *recordingFormat) Key(key string) error {
+	f.calls = append(f.calls, key+":")
+	return nil
+}
+
+func (f *recordingFormat) EndObject() error {
+	f.calls = append(f.calls, "}")
+	return nil
+}
+
+func (f *recordingFormat) BeginArray() error {
+	f.calls = append(f.calls, "[")
+	return nil
+}
+
+func (f *recordingFormat) EndArray() error {
+	f.calls = append(f.calls, "]")
+	return nil
+}
+
+func (f *recordingFormat) Scalar(v reflect.Value) error {
+	if !v.IsValid() {
+		f.calls = append(f.calls, "null")
+		return nil
+	}
+	f.calls = append(f.calls, fmt.Sprintf("%s(%v)", v.Kind(), v.Interface()))
+	return nil
+}
+
+// failingFormat fails to encode strings.
+type failingFormat struct {
+	recordingFormat
+}
+
+func (f *failingFormat) Scalar(v reflect.Value) error {
+	if v.Kind() == reflect.String {
+		return errors.New("no strings")
+	}
+	return f.recordingFormat.Scalar(v)
+}
+
+func TestCustomMarshaller_MarshalFormat(t *testing.T) {
+	testCases := []struct {
+		Name          string
+		Input         any
+		Configure     func(m *CustomMarshaller)
+		ExpectedError error
+		ExpectedCalls string
+	}{
+		{
+			Name:          "nested structs",
+			Input:         parentStruct{ChildStructVar: childStruct{GrandChildStructVar: grandChildStruct{StringVar: "str"}}},
+			ExpectedCalls: "{ child_struct_var: { grand_child_struct_var: { string_var: string(str) } } }",
+		},
+		{
+			Name: "options",
+			Input: optionStruct{
+				QuotedIntVar: 1,
+				ZeroerVar:    positiveNumber{Value: 2},
+			},
+			ExpectedCalls: "{ zeroer_var: { value: int(2) } quoted_int_var: string(1) quoted_bool_var: string(false) quoted_string_var: string(\"\") quoted_float_ptr_var: null -: string() }",
+		},
+		{
+			Name:          "maps and slices",
+			Input:         map[string][]uint8{"b": {1}, "a": nil},
+			ExpectedCalls: "{ a: null b: string(AQ==) }",
+		},
+		{
+			Name:          "marshalers are replayed",
+			Input:         []interface{}{spacedJSON{}, upperText("up"), tagAware{}},
+			ExpectedCalls: "[ { a: [ int64(1) int64(2) ] } string(UP) string(custom) ]",
+		},
+		{
+			Name:  "registered encoders are replayed",
+			Input: []float32{1.5},
+			Configure: func(m *CustomMarshaller) {
+				m.RegisterEncoder(reflect.TypeOf(float32(0)), func(w io.Writer, v reflect.Value) error {
+					_, err := io.WriteString(w, `{"big":18446744073709551615,"float":1.5,"null":null,"ok":true}`)
+					return err
+				})
+			},
+			ExpectedCalls: "[ { big: uint64(18446744073709551615) float: float64(1.5) null: null ok: bool(true) } ]",
+		},
+		{
+			Name:  "complex numbers",
+			Input: []complex64{1 + 2i},
+			Configure: func(m *CustomMarshaller) {
+				m.ComplexEncoding = ComplexAsObject
+			},
+			ExpectedCalls: "[ { real: float32(1) imag: float32(2) } ]",
+		},
+		{
+			Name:          "unsupported type",
+			Input:         grandChildStruct{StringVar: "str"},
+			ExpectedError: errors.New("failed to marshal string_var: no strings"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+			if testCase.Configure != nil {
+				testCase.Configure(m)
+			}
+			var f Format = &recordingFormat{}
+			if testCase.ExpectedError != nil {
+				f = &failingFormat{}
+			}
+			err := m.MarshalFormat(f, testCase.Input)
+			assertError(t, testCase.ExpectedError, err)
+			if err == nil {
+				assert.Equal(t, testCase.ExpectedCalls, strings.Join(f.(*recordingFormat).calls, " "))
+			}
+		})
+	}
+}
+
+// nativeFormat implements the optional Format interfaces, writing structs as
+// arrays of their fields.
+type nativeFormat struct {
+	recordingFormat
+}
+
+func (f *nativeFormat) Binary(b []byte) error {
+	f.calls = append(f.calls, fmt.Sprintf("bin(%x)", b))
+	return nil
+}
+
+func (f *nativeFormat) Extension(code int8, data []byte) error {
+	f.calls = append(f.calls, fmt.Sprintf("ext%d(%s)", code, data))
+	return nil
+}
+
+func (f *nativeFormat) Struct(s *StructWriter) error {
+	f.calls = append(f.calls, "(")
+	for i := 0; i < s.NumField(); i++ {
+		field := s.Field(i)
+		if field.Omitted {
+			f.calls = append(f.calls, field.Name+"!")
+			continue
+		}
+		if strings.Contains(field.Options, "string") {
+			return s.FieldError(i, errors.New("no quoted fields"))
+		}
+		f.calls = append(f.calls, field.Name+"=")
+		err := s.WriteField(i)
+		if err != nil {
+			return err
+		}
+	}
+	f.calls = append(f.calls, ")")
+	return nil
+}
+
+type nativeStruct struct {
+	Data    []byte         `custom:"data"`
+	Point   msgpackPoint   `custom:"point"`
+	Omitted int            `custom:"omitted,omitempty"`
+	Child   *nativeStruct  `custom:"child"`
+	Values  map[string]int `custom:"values"`
+}
+
+func TestCustomMarshaller_MarshalFormat_OptionalInterfaces(t *testing.T) {
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	m.RegisterMsgpackExtension(reflect.TypeOf(msgpackPoint{}), 3,
+		func(v reflect.Value) ([]byte, error) {
+			p := v.Interface().(msgpackPoint)
+			return []byte(fmt.Sprintf("%d,%d", p.X, p.Y)), nil
+		},
+		func(data []byte, v reflect.Value) error {
+			return nil
+		})
+
+	f := &nativeFormat{}
+	err := m.MarshalFormat(f, nativeStruct{
+		Data:  []byte{1, 2},
+		Point: msgpackPoint{X: 1, Y: 2},
+		Child: &nativeStruct{},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "( data= bin(0102) point= ext3(1,2) omitted! child= ( data= null point= ext3(0,0) omitted! child= null values= null ) values= null )", strings.Join(f.calls, " "))
+
+	// Formats without the optional interfaces get the usual walk.
+	r := &recordingFormat{}
+	err = m.MarshalFormat(r, nativeStruct{Data: []byte{1, 2}})
+	assert.NoError(t, err)
+	assert.Equal(t, "{ data: string(AQI=) point: { x: int32(0) y: int32(0) } child: null values: null }", strings.Join(r.calls, " "))
+
+	err = m.MarshalFormat(&nativeFormat{}, optionStruct{})
+	assert.EqualError(t, err, "failed to marshal quoted_int_var: no quoted fields")
+}
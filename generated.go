@@ -0,0 +1,310 @@
+package structTags
+
+import (
+	"reflect"
+)
+
+// GeneratedMarshaler is implemented by struct types with methods generated by
+// cmd/structtags-gen. The marshaller calls MarshalCustom instead of walking
+// the struct's fields through reflection, as long as the schema matches the
+// fields it would have walked.
+type GeneratedMarshaler interface {
+	CustomTagSchema() *GeneratedSchema
+	MarshalCustom(e *GeneratedEncoder) error
+}
+
+// GeneratedUnmarshaler is the unmarshalling counterpart of GeneratedMarshaler.
+type GeneratedUnmarshaler interface {
+	CustomTagSchema() *GeneratedSchema
+	UnmarshalCustom(d *GeneratedDecoder) error
+}
+
+// GeneratedSchema describes the fields which generated methods were written
+// for.
+type GeneratedSchema struct {
+	TargetTag          string
+	IgnoreTagWithValue string
+	Fields             []GeneratedField
+}
+
+// GeneratedField is a struct field handled by generated methods, in the order
+// they handle them.
+type GeneratedField struct {
+	Key     string
+	Index   []int
+	Options string
+}
+
+var (
+	generatedMarshalerType   = reflect.TypeOf((*GeneratedMarshaler)(nil)).Elem()
+	generatedUnmarshalerType = reflect.TypeOf((*GeneratedUnmarshaler)(nil)).Elem()
+)
+
+// matchesSchema reports whether the generated methods described by schema
+// handle exactly the fields of p, so that they produce the same output.
+func (m *CustomMarshaller) matchesSchema(p *structPlan, schema *GeneratedSchema) bool {
+	if schema == nil || schema.TargetTag != m.TargetTag || schema.IgnoreTagWithValue != m.IgnoreTagWithValue {
+		return false
+	}
+	if len(schema.Fields) != len(p.fields) {
+		return false
+	}
+	for i, f := range schema.Fields {
+		planned := p.fields[i]
+		if f.Key != planned.TagValue || len(f.Index) != len(planned.Index) {
+			return false
+		}
+		for j := range f.Index {
+			if f.Index[j] != planned.Index[j] {
+				return false
+			}
+		}
+		options := tagOptions(f.Options)
+		if options.Contains("omitempty") != planned.OmitEmpty ||
+			options.Contains("omitzero") != planned.OmitZero ||
+			(options.Contains("string") && canQuote(planned.Type)) != planned.Quoted ||
+			parseBytesEncoding(options, planned.Type) != planned.Bytes {
+			return false
+		}
+	}
+
+	return true
+}
+
+// generatedMarshaler returns the generated methods of the struct v, when they
+// can be used in place of the plan p. They only write JSON.
+func (m *CustomMarshaller) generatedMarshaler(w *encodeState, v reflect.Value, p *structPlan) (GeneratedMarshaler, *jsonFormat, bool) {
+	if !p.generatedMarshaler || m.IgnoreGeneratedMethods || len(m.encoders) > 0 || !v.CanInterface() {
+		return nil, nil, false
+	}
+	f, ok := w.asJSON()
+	if !ok {
+		return nil, nil, false
+	}
+
+	if !v.CanAddr() {
+		// The methods have pointer receivers, so that the struct isn't copied
+		// for every call, but an unaddressable struct still has to be copied
+		// once.
+		c := reflect.New(v.Type())
+		c.Elem().Set(v)
+		return c.Interface().(GeneratedMarshaler), f, true
+	}
+
+	return v.Addr().Interface().(GeneratedMarshaler), f, true
+}
+
+// generatedUnmarshaler is the unmarshalling counterpart of generatedMarshaler.
+func (m *CustomMarshaller) generatedUnmarshaler(v reflect.Value, p *structPlan) (GeneratedUnmarshaler, bool) {
+	if !p.generatedUnmarshaler || m.IgnoreGeneratedMethods || len(m.decoders) > 0 || !v.CanAddr() {
+		return nil, false
+	}
+
+	return v.Addr().Interface().(GeneratedUnmarshaler), true
+}
+
+// GeneratedEncoder writes the fields of a struct for its generated
+// MarshalCustom method. Errors are sticky: once a field fails, the following
+// calls do nothing, and Err reports the failure.
+type GeneratedEncoder struct {
+	m    *CustomMarshaller
+	w    *encodeState
+	json *jsonFormat
+	plan *structPlan
+	v    reflect.Value
+	err  error
+}
+
+// Field writes the i-th field of the schema, which p points to. Strings,
+// bools and numbers of predeclared types are written directly, and anything
+// else goes through the marshaller, exactly as if the struct were walked
+// through reflection.
+func (e *GeneratedEncoder) Field(i int, p interface{}) {
+	if e.err != nil {
+		return
+	}
+	field := &e.plan.fields[i]
+	value := reflect.ValueOf(p).Elem()
+	if field.Quoted || field.Bytes != defaultBytes {
+		e.reflectField(field, value)
+		return
+	}
+
+	var empty bool
+	switch p := p.(type) {
+	case *string:
+		empty = *p == ""
+	case *bool:
+		empty = !*p
+	case *int, *int8, *int16, *int32, *int64:
+		empty = value.Int() == 0
+	case *uint, *uint8, *uint16, *uint32, *uint64, *uintptr:
+		empty = value.Uint() == 0
+	case *float32, *float64:
+		empty = value.Float() == 0
+	default:
+		e.reflectField(field, value)
+		return
+	}
+	if empty && (field.OmitEmpty || field.OmitZero) {
+		return
+	}
+	e.writeKey(field)
+	e.done(field, e.json.Scalar(value))
+}
+
+// Err returns the first error encountered while writing fields.
+func (e *GeneratedEncoder) Err() error {
+	return e.err
+}
+
+// reflectField writes the field value through reflection, using the same
+// encoder as the reflection walk.
+func (e *GeneratedEncoder) reflectField(field *fieldPlan, value reflect.Value) {
+	if !e.v.CanAddr() {
+		// Generated methods work on an addressable copy of the struct, but the
+		// reflection walk can't use the pointer methods of fields of an
+		// unaddressable struct, so neither can this.
+		value, _ = fieldByIndex(e.v, field.Index)
+	}
+	if field.OmitEmpty && isEmptyValue(value) {
+		return
+	}
+	if field.OmitZero && isZeroValue(value) {
+		return
+	}
+	e.writeKey(field)
+	e.done(field, field.encode(e.m, e.w, value))
+}
+
+// writeKey writes the key of field, and descends into it.
+func (e *GeneratedEncoder) writeKey(field *fieldPlan) {
+	e.json.rawKey(field.key)
+	e.w.path.pushField(field.TagValue)
+}
+
+// done records the outcome of writing the value of field.
+func (e *GeneratedEncoder) done(field *fieldPlan, err error) {
+	if err != nil {
+		e.err = e.w.wrap(field.Type, err)
+		return
+	}
+	e.w.path.pop()
+}
+
+// marshalGenerated writes the struct v using its generated MarshalCustom
+// method.
+func (m *CustomMarshaller) marshalGenerated(w *encodeState, f *jsonFormat, v reflect.Value, p *structPlan, g GeneratedMarshaler) error {
+	if w.generatedDepth == len(w.generated) {
+		w.generated = append(w.generated, &GeneratedEncoder{})
+	}
+	e := w.generated[w.generatedDepth]
+	*e = GeneratedEncoder{
+		m:    m,
+		w:    w,
+		json: f,
+		plan: p,
+		v:    v,
+	}
+	f.BeginObject()
+	w.generatedDepth++
+	err := g.MarshalCustom(e)
+	w.generatedDepth--
+	if err != nil {
+		return err
+	}
+	f.EndObject()
+
+	return nil
+}
+
+// GeneratedDecoder reads the fields of a JSON object for a generated
+// UnmarshalCustom method, in document order. Errors are sticky: once a field
+// fails, Next reports false, and Err reports the failure.
+type GeneratedDecoder struct {
+	m    *CustomMarshaller
+	d    *decodeState
+	plan *structPlan
+	r    *jsonReader
+	// field is the schema index of the current field, and pending reports
+	// whether its value is yet to be read.
+	field   int
+	pending bool
+	done    bool
+	err     error
+}
+
+// Next advances to the next key of the object which matches a field of the
+// schema, reporting false once there are none left.
+func (d *GeneratedDecoder) Next() bool {
+	if d.field >= 0 {
+		d.d.path.pop()
+		d.field = -1
+	}
+	if d.pending {
+		d.r.value()
+		d.pending = false
+	}
+	for d.err == nil && !d.done {
+		if !d.r.more('}') {
+			d.done = true
+			break
+		}
+		field, ok := d.plan.matchIndex(d.r.key())
+		if ok {
+			d.field = field
+			d.pending = true
+			d.d.path.pushField(d.plan.fields[field].TagValue)
+			return true
+		}
+		d.r.value()
+	}
+
+	return false
+}
+
+// Field returns the schema index of the current field.
+func (d *GeneratedDecoder) Field() int {
+	return d.field
+}
+
+// Decode stores the value of the current field in the field which p points
+// to, exactly as if the struct were walked through reflection.
+func (d *GeneratedDecoder) Decode(p interface{}) {
+	if d.err != nil || !d.pending {
+		return
+	}
+	d.pending = false
+	field := &d.plan.fields[d.field]
+	err := d.m.unmarshalFieldNext(d.d, d.r, reflect.ValueOf(p).Elem(), field)
+	if err != nil {
+		d.err = d.d.wrap(field.Type, err)
+	}
+}
+
+// Err returns the first error encountered while decoding fields.
+func (d *GeneratedDecoder) Err() error {
+	return d.err
+}
+
+// unmarshalGenerated decodes the members of the JSON object being read by r
+// into a struct using its generated UnmarshalCustom method.
+func (m *CustomMarshaller) unmarshalGenerated(d *decodeState, r *jsonReader, p *structPlan, g GeneratedUnmarshaler) error {
+	gd := &GeneratedDecoder{
+		m:     m,
+		d:     d,
+		plan:  p,
+		r:     r,
+		field: -1,
+	}
+	err := g.UnmarshalCustom(gd)
+	if err != nil {
+		return err
+	}
+	// Move past whatever the method left unread, since the rest of the
+	// document follows the object.
+	for gd.Next() {
+	}
+
+	return gd.err
+}
@@ -0,0 +1,247 @@
+package gentest
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/foresthoffman/structTags"
+	"github.com/stretchr/testify/assert"
+)
+
+func newMarshallers() (generated, reflected *structTags.CustomMarshaller) {
+	generated = structTags.NewCustomMarshaller("custom", "-")
+	reflected = structTags.NewCustomMarshaller("custom", "-")
+	reflected.IgnoreGeneratedMethods = true
+
+	return generated, reflected
+}
+
+func newRecord() Record {
+	pointer := "<pointer>"
+	enabled := true
+
+	return Record{
+		Name:     "name   & é",
+		Count:    3,
+		Ratio:    0.5,
+		Small:    -8,
+		Unsigned: 32,
+		Flag:     true,
+		Ignored:  "ignored",
+		Untagged: "untagged",
+		Dash:     "dash",
+		Tags:     []string{"a", "b"},
+		Labels:   map[string]string{"b": "2", "a": "1"},
+		Child:    Child{Name: "child", Value: 1},
+		Children: []*Child{{Name: "first"}, nil},
+		When:     time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Any:      map[string]interface{}{"nested": []interface{}{1.5, "x"}},
+		Pointer:  &pointer,
+		Data:     []byte{0xab, 0xcd},
+		Number:   42,
+		Inline:   Extra{Note: "note"},
+		Version:  Version{Major: 2},
+		private:  "private",
+		Base:     Base{ID: "id", Name: "base"},
+		Quoted:   &Quoted{Level: 7, Enabled: &enabled},
+	}
+}
+
+func TestGenerated_Marshal(t *testing.T) {
+	testCases := []struct {
+		Name      string
+		Input     interface{}
+		Configure func(m *structTags.CustomMarshaller)
+	}{
+		{
+			Name:  "record",
+			Input: newRecord(),
+		},
+		{
+			Name:  "record pointer",
+			Input: func() *Record { r := newRecord(); return &r }(),
+		},
+		{
+			Name:  "zero record",
+			Input: Record{},
+		},
+		{
+			Name:  "nested in reflected struct",
+			Input: Plain{Record: newRecord()},
+		},
+		{
+			Name:  "slice of records",
+			Input: []Record{newRecord(), {}},
+		},
+		{
+			Name:  "escaping",
+			Input: newRecord(),
+			Configure: func(m *structTags.CustomMarshaller) {
+				m.EscapeHTML = true
+				m.ASCIIOnly = true
+			},
+		},
+		{
+			Name:  "nil as empty",
+			Input: Record{},
+			Configure: func(m *structTags.CustomMarshaller) {
+				m.NilSliceAsEmpty = true
+				m.NilMapAsEmpty = true
+			},
+		},
+		{
+			Name:  "mismatched schema",
+			Input: newRecord(),
+			Configure: func(m *structTags.CustomMarshaller) {
+				m.UntaggedFields = structTags.SkipUntagged
+			},
+		},
+		{
+			Name:  "registered encoder",
+			Input: newRecord(),
+			Configure: func(m *structTags.CustomMarshaller) {
+				m.RegisterEncoder(reflect.TypeOf(Child{}), func(w io.Writer, v reflect.Value) error {
+					_, err := io.WriteString(w, `"child"`)
+					return err
+				})
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			generated, reflected := newMarshallers()
+			if testCase.Configure != nil {
+				testCase.Configure(generated)
+				testCase.Configure(reflected)
+			}
+			expected, err := reflected.Marshal(testCase.Input)
+			assert.NoError(t, err)
+			output, err := generated.Marshal(testCase.Input)
+			assert.NoError(t, err)
+			assert.Equal(t, string(expected), string(output))
+		})
+	}
+}
+
+func TestGenerated_MarshalError(t *testing.T) {
+	generated, reflected := newMarshallers()
+	record := newRecord()
+	record.Ratio = -1
+	record.Any = []interface{}{make(chan int)}
+
+	_, expected := reflected.Marshal(record)
+	_, err := generated.Marshal(record)
+	assert.Error(t, err)
+	assert.Equal(t, expected.Error(), err.Error())
+	assert.True(t, errors.Is(err, structTags.ErrUnsupportedType))
+}
+
+func TestGenerated_Unmarshal(t *testing.T) {
+	testCases := []struct {
+		Name          string
+		Input         string
+		ExpectedError error
+	}{
+		{
+			Name: "record",
+			Input: `{"name":"name","count":3,"ratio":0.5,"small":-8,"unsigned":32,"flag":true,"Untagged":"untagged",` +
+				`"-":"dash","tags":["a"],"labels":{"a":"1"},"child":{"name":"child","value":1},` +
+				`"children":[{"name":"first"},null],"when":"2020-01-02T03:04:05Z","any":{"nested":[1.5,"x"]},` +
+				`"pointer":"pointer","data":"abcd","number":"42","note":"note","id":"id","base_name":"base",` +
+				`"level":"7","enabled":true,"unknown":1}`,
+		},
+		{
+			Name:  "case-insensitive keys",
+			Input: `{"NAME":"name","Base_Name":"base"}`,
+		},
+		{
+			Name:  "duplicate keys",
+			Input: `{"NAME":"a","name":"b","child":{"name":"c"},"Name":"d","unknown":[{"name":"e"}]}`,
+		},
+		{
+			Name:  "null embedded pointer fields",
+			Input: `{"enabled":null}`,
+		},
+		{
+			Name:          "type mismatch",
+			Input:         `{"child":{"value":"1"}}`,
+			ExpectedError: errors.New("failed to unmarshal child.value: cannot unmarshal string into int"),
+		},
+		{
+			Name:          "invalid quoted value",
+			Input:         `{"level":"x"}`,
+			ExpectedError: errors.New("failed to unmarshal level: cannot unmarshal invalid quoted value \"x\" into uint"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			generated, reflected := newMarshallers()
+			var expected, output Record
+			expectedErr := reflected.Unmarshal([]byte(testCase.Input), &expected)
+			err := generated.Unmarshal([]byte(testCase.Input), &output)
+			if testCase.ExpectedError != nil {
+				assert.EqualError(t, expectedErr, testCase.ExpectedError.Error())
+				assert.EqualError(t, err, testCase.ExpectedError.Error())
+				return
+			}
+			assert.NoError(t, expectedErr)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, output)
+		})
+	}
+}
+
+func TestGenerated_UnmarshalDuplicateKeys(t *testing.T) {
+	generated, _ := newMarshallers()
+	// Decoding used to depend on map iteration order, so repeat it.
+	for i := 0; i < 200; i++ {
+		var output Record
+		err := generated.Unmarshal([]byte(`{"NAME":"a","name":"b","Name":"c"}`), &output)
+		assert.NoError(t, err)
+		assert.Equal(t, "c", output.Name)
+	}
+}
+
+func TestGenerated_RoundTrip(t *testing.T) {
+	generated, _ := newMarshallers()
+	record := newRecord()
+	record.Ignored = ""
+	record.private = ""
+	record.Any = nil
+
+	b, err := generated.Marshal(record)
+	assert.NoError(t, err)
+	var output Record
+	err = generated.Unmarshal(b, &output)
+	assert.NoError(t, err)
+	assert.Equal(t, record, output)
+}
+
+func BenchmarkGenerated_Marshal(b *testing.B) {
+	generated, reflected := newMarshallers()
+	children := make([]Child, 100)
+	for i := range children {
+		children[i] = Child{Name: "child", Value: i}
+	}
+	for _, m := range []struct {
+		Name string
+		M    *structTags.CustomMarshaller
+	}{{"generated", generated}, {"reflected", reflected}} {
+		b.Run(m.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			var buf []byte
+			for i := 0; i < b.N; i++ {
+				var err error
+				buf, err = m.M.AppendMarshal(buf[:0], children)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+// Code generated by structtags-gen. DO NOT EDIT.
+
+package gentest
+
+import "github.com/foresthoffman/structTags"
+
+var recordCustomTagSchema = &structTags.GeneratedSchema{
+	TargetTag:          "custom",
+	IgnoreTagWithValue: "-",
+	Fields: []structTags.GeneratedField{
+		{Key: "name", Index: []int{0}},
+		{Key: "count", Index: []int{1}, Options: "omitempty"},
+		{Key: "ratio", Index: []int{2}},
+		{Key: "small", Index: []int{3}},
+		{Key: "unsigned", Index: []int{4}, Options: "omitzero"},
+		{Key: "flag", Index: []int{5}},
+		{Key: "Untagged", Index: []int{7}},
+		{Key: "-", Index: []int{8}},
+		{Key: "tags", Index: []int{9}, Options: "omitempty"},
+		{Key: "labels", Index: []int{10}},
+		{Key: "child", Index: []int{11}},
+		{Key: "children", Index: []int{12}},
+		{Key: "when", Index: []int{13}},
+		{Key: "any", Index: []int{14}},
+		{Key: "pointer", Index: []int{15}},
+		{Key: "data", Index: []int{16}, Options: "hex"},
+		{Key: "number", Index: []int{17}, Options: "string"},
+		{Key: "note", Index: []int{18, 0}},
+		{Key: "version", Index: []int{19}},
+		{Key: "id", Index: []int{21, 0}},
+		{Key: "base_name", Index: []int{21, 1}},
+		{Key: "level", Index: []int{22, 0}, Options: "string"},
+		{Key: "enabled", Index: []int{22, 1}},
+	},
+}
+
+// CustomTagSchema describes the fields handled by MarshalCustom and
+// UnmarshalCustom.
+func (Record) CustomTagSchema() *structTags.GeneratedSchema {
+	return recordCustomTagSchema
+}
+
+// MarshalCustom writes the fields of v for a structTags.CustomMarshaller.
+func (v *Record) MarshalCustom(e *structTags.GeneratedEncoder) error {
+	e.Field(0, &v.Name)
+	e.Field(1, &v.Count)
+	e.Field(2, &v.Ratio)
+	e.Field(3, &v.Small)
+	e.Field(4, &v.Unsigned)
+	e.Field(5, &v.Flag)
+	e.Field(6, &v.Untagged)
+	e.Field(7, &v.Dash)
+	e.Field(8, &v.Tags)
+	e.Field(9, &v.Labels)
+	e.Field(10, &v.Child)
+	e.Field(11, &v.Children)
+	e.Field(12, &v.When)
+	e.Field(13, &v.Any)
+	e.Field(14, &v.Pointer)
+	e.Field(15, &v.Data)
+	e.Field(16, &v.Number)
+	e.Field(17, &v.Inline.Note)
+	e.Field(18, &v.Version)
+	e.Field(19, &v.Base.ID)
+	e.Field(20, &v.Base.Name)
+	if v.Quoted != nil {
+		e.Field(21, &v.Quoted.Level)
+	}
+	if v.Quoted != nil {
+		e.Field(22, &v.Quoted.Enabled)
+	}
+	return e.Err()
+}
+
+// UnmarshalCustom reads the fields of v for a structTags.CustomMarshaller.
+func (v *Record) UnmarshalCustom(d *structTags.GeneratedDecoder) error {
+	for d.Next() {
+		switch d.Field() {
+		case 0:
+			d.Decode(&v.Name)
+		case 1:
+			d.Decode(&v.Count)
+		case 2:
+			d.Decode(&v.Ratio)
+		case 3:
+			d.Decode(&v.Small)
+		case 4:
+			d.Decode(&v.Unsigned)
+		case 5:
+			d.Decode(&v.Flag)
+		case 6:
+			d.Decode(&v.Untagged)
+		case 7:
+			d.Decode(&v.Dash)
+		case 8:
+			d.Decode(&v.Tags)
+		case 9:
+			d.Decode(&v.Labels)
+		case 10:
+			d.Decode(&v.Child)
+		case 11:
+			d.Decode(&v.Children)
+		case 12:
+			d.Decode(&v.When)
+		case 13:
+			d.Decode(&v.Any)
+		case 14:
+			d.Decode(&v.Pointer)
+		case 15:
+			d.Decode(&v.Data)
+		case 16:
+			d.Decode(&v.Number)
+		case 17:
+			d.Decode(&v.Inline.Note)
+		case 18:
+			d.Decode(&v.Version)
+		case 19:
+			d.Decode(&v.Base.ID)
+		case 20:
+			d.Decode(&v.Base.Name)
+		case 21:
+			if v.Quoted == nil {
+				v.Quoted = new(Quoted)
+			}
+			d.Decode(&v.Quoted.Level)
+		case 22:
+			if v.Quoted == nil {
+				v.Quoted = new(Quoted)
+			}
+			d.Decode(&v.Quoted.Enabled)
+		}
+	}
+	return d.Err()
+}
+
+var childCustomTagSchema = &structTags.GeneratedSchema{
+	TargetTag:          "custom",
+	IgnoreTagWithValue: "-",
+	Fields: []structTags.GeneratedField{
+		{Key: "name", Index: []int{0}},
+		{Key: "value", Index: []int{1}},
+	},
+}
+
+// CustomTagSchema describes the fields handled by MarshalCustom and
+// UnmarshalCustom.
+func (Child) CustomTagSchema() *structTags.GeneratedSchema {
+	return childCustomTagSchema
+}
+
+// MarshalCustom writes the fields of v for a structTags.CustomMarshaller.
+func (v *Child) MarshalCustom(e *structTags.GeneratedEncoder) error {
+	e.Field(0, &v.Name)
+	e.Field(1, &v.Value)
+	return e.Err()
+}
+
+// UnmarshalCustom reads the fields of v for a structTags.CustomMarshaller.
+func (v *Child) UnmarshalCustom(d *structTags.GeneratedDecoder) error {
+	for d.Next() {
+		switch d.Field() {
+		case 0:
+			d.Decode(&v.Name)
+		case 1:
+			d.Decode(&v.Value)
+		}
+	}
+	return d.Err()
+}
+
+var baseCustomTagSchema = &structTags.GeneratedSchema{
+	TargetTag:          "custom",
+	IgnoreTagWithValue: "-",
+	Fields: []structTags.GeneratedField{
+		{Key: "id", Index: []int{0}},
+		{Key: "base_name", Index: []int{1}},
+	},
+}
+
+// CustomTagSchema describes the fields handled by MarshalCustom and
+// UnmarshalCustom.
+func (Base) CustomTagSchema() *structTags.GeneratedSchema {
+	return baseCustomTagSchema
+}
+
+// MarshalCustom writes the fields of v for a structTags.CustomMarshaller.
+func (v *Base) MarshalCustom(e *structTags.GeneratedEncoder) error {
+	e.Field(0, &v.ID)
+	e.Field(1, &v.Name)
+	return e.Err()
+}
+
+// UnmarshalCustom reads the fields of v for a structTags.CustomMarshaller.
+func (v *Base) UnmarshalCustom(d *structTags.GeneratedDecoder) error {
+	for d.Next() {
+		switch d.Field() {
+		case 0:
+			d.Decode(&v.ID)
+		case 1:
+			d.Decode(&v.Name)
+		}
+	}
+	return d.Err()
+}
+
+var extraCustomTagSchema = &structTags.GeneratedSchema{
+	TargetTag:          "custom",
+	IgnoreTagWithValue: "-",
+	Fields: []structTags.GeneratedField{
+		{Key: "note", Index: []int{0}},
+	},
+}
+
+// CustomTagSchema describes the fields handled by MarshalCustom and
+// UnmarshalCustom.
+func (Extra) CustomTagSchema() *structTags.GeneratedSchema {
+	return extraCustomTagSchema
+}
+
+// MarshalCustom writes the fields of v for a structTags.CustomMarshaller.
+func (v *Extra) MarshalCustom(e *structTags.GeneratedEncoder) error {
+	e.Field(0, &v.Note)
+	return e.Err()
+}
+
+// UnmarshalCustom reads the fields of v for a structTags.CustomMarshaller.
+func (v *Extra) UnmarshalCustom(d *structTags.GeneratedDecoder) error {
+	for d.Next() {
+		switch d.Field() {
+		case 0:
+			d.Decode(&v.Note)
+		}
+	}
+	return d.Err()
+}
+
+var quotedCustomTagSchema = &structTags.GeneratedSchema{
+	TargetTag:          "custom",
+	IgnoreTagWithValue: "-",
+	Fields: []structTags.GeneratedField{
+		{Key: "level", Index: []int{0}, Options: "string"},
+		{Key: "enabled", Index: []int{1}},
+	},
+}
+
+// CustomTagSchema describes the fields handled by MarshalCustom and
+// UnmarshalCustom.
+func (Quoted) CustomTagSchema() *structTags.GeneratedSchema {
+	return quotedCustomTagSchema
+}
+
+// MarshalCustom writes the fields of v for a structTags.CustomMarshaller.
+func (v *Quoted) MarshalCustom(e *structTags.GeneratedEncoder) error {
+	e.Field(0, &v.Level)
+	e.Field(1, &v.Enabled)
+	return e.Err()
+}
+
+// UnmarshalCustom reads the fields of v for a structTags.CustomMarshaller.
+func (v *Quoted) UnmarshalCustom(d *structTags.GeneratedDecoder) error {
+	for d.Next() {
+		switch d.Field() {
+		case 0:
+			d.Decode(&v.Level)
+		case 1:
+			d.Decode(&v.Enabled)
+		}
+	}
+	return d.Err()
+}
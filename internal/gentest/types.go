@@ -0,0 +1,76 @@
+// Package gentest holds types with methods generated by structtags-gen, which
+// are checked against the reflection walk.
+package gentest
+
+import (
+	"fmt"
+	"time"
+)
+
+//go:generate go run ../../cmd/structtags-gen -tag custom -type Record,Child,Base,Extra,Quoted
+
+// Record covers the common field kinds, options and embedded structs.
+type Record struct {
+	Name     string  `custom:"name"`
+	Count    int     `custom:"count,omitempty"`
+	Ratio    float64 `custom:"ratio"`
+	Small    int8    `custom:"small"`
+	Unsigned uint32  `custom:"unsigned,omitzero"`
+	Flag     bool    `custom:"flag"`
+	Ignored  string  `custom:"-"`
+	Untagged string
+	Dash     string            `custom:"-,"`
+	Tags     []string          `custom:"tags,omitempty"`
+	Labels   map[string]string `custom:"labels"`
+	Child    Child             `custom:"child"`
+	Children []*Child          `custom:"children"`
+	When     time.Time         `custom:"when"`
+	Any      interface{}       `custom:"any"`
+	Pointer  *string           `custom:"pointer"`
+	Data     []byte            `custom:"data,hex"`
+	Number   int64             `custom:"number,string"`
+	Inline   Extra             `custom:",inline"`
+	Version  Version           `custom:"version"`
+	private  string
+	Base
+	*Quoted
+}
+
+// Child is nested inside Record.
+type Child struct {
+	Name  string `custom:"name"`
+	Value int    `custom:"value"`
+}
+
+// Base is embedded by value into Record.
+type Base struct {
+	ID   string `custom:"id"`
+	Name string `custom:"base_name"`
+}
+
+// Extra has its fields inlined into Record.
+type Extra struct {
+	Note string `custom:"note"`
+}
+
+// Quoted is embedded by pointer into Record.
+type Quoted struct {
+	Level   uint  `custom:"level,string"`
+	Enabled *bool `custom:"enabled"`
+}
+
+// Version marshals itself through a pointer method, which the reflection walk
+// only calls when the struct holding it is addressable.
+type Version struct {
+	Major int
+}
+
+// MarshalJSON implements json.Marshaler.
+func (v *Version) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`"v%d"`, v.Major)), nil
+}
+
+// Plain has no generated methods, so it's walked through reflection.
+type Plain struct {
+	Record Record `custom:"record"`
+}
@@ -0,0 +1,127 @@
+package structTags
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// mapKey pairs a map key with the object key it's marshalled as.
+type mapKey struct {
+	Name  string
+	Value reflect.Value
+}
+
+// resolveMapKeys returns the keys of the map v, sorted by the object keys
+// they're marshalled as. Like encoding/json, keys of a string kind are used
+// directly, encoding.TextMarshaler keys are marshalled, and integer keys are
+// formatted in base 10.
+func resolveMapKeys(v reflect.Value) ([]mapKey, error) {
+	if !canResolveMapKey(v.Type().Key()) {
+		return nil, &UnsupportedTypeError{Type: v.Type()}
+	}
+	keys := make([]mapKey, 0, v.Len())
+	for _, key := range v.MapKeys() {
+		name, err := resolveMapKey(key)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, mapKey{
+			Name:  name,
+			Value: key,
+		})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+
+	return keys, nil
+}
+
+func resolveMapKey(key reflect.Value) (string, error) {
+	if key.Kind() == reflect.String {
+		return key.String(), nil
+	}
+	if key.Type().Implements(textMarshalerType) {
+		if key.Kind() == reflect.Ptr && key.IsNil() {
+			return "", nil
+		}
+		b, err := key.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return "", fmt.Errorf("failed to call MarshalText for map key type %s: %w", key.Type(), err)
+		}
+		return string(b), nil
+	}
+	switch key.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10), nil
+	}
+
+	return "", &UnsupportedTypeError{Type: key.Type()}
+}
+
+// canResolveMapKey reports whether map keys of type t can be marshalled into
+// object keys.
+func canResolveMapKey(t reflect.Type) bool {
+	if t.Kind() == reflect.String || t.Implements(textMarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+
+	return false
+}
+
+// canParseMapKey reports whether object keys can be unmarshalled into map keys
+// of type t.
+func canParseMapKey(t reflect.Type) bool {
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+
+	return false
+}
+
+// parseMapKey unmarshals the object key name into a map key of type t. Like
+// encoding/json, encoding.TextUnmarshaler takes priority over the key's kind.
+func parseMapKey(name string, t reflect.Type) (reflect.Value, error) {
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		key := reflect.New(t)
+		err := key.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(name))
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("failed to call UnmarshalText for map key type %s: %w", t, err)
+		}
+		return key.Elem(), nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(name).Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(name, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("cannot unmarshal object key %q into %s", name, t)
+		}
+		return reflect.ValueOf(n).Convert(t), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(name, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("cannot unmarshal object key %q into %s", name, t)
+		}
+		return reflect.ValueOf(n).Convert(t), nil
+	}
+
+	return reflect.Value{}, &UnsupportedTypeError{Type: t}
+}
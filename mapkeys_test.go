@@ -0,0 +1,96 @@
+package structTags
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/netip"
+	"testing"
+)
+
+type colorName string
+
+type mapKeyStruct struct {
+	IntKeyVar    map[int]string        `json:"intKeyVar" custom:"int_key_var"`
+	Int8KeyVar   map[int8]int          `json:"int8KeyVar" custom:"int8_key_var"`
+	UintKeyVar   map[uint64]bool       `json:"uintKeyVar" custom:"uint_key_var"`
+	NamedKeyVar  map[colorName]int     `json:"namedKeyVar" custom:"named_key_var"`
+	TextKeyVar   map[netip.Addr]string `json:"textKeyVar" custom:"text_key_var"`
+	StringKeyVar map[string]string     `json:"stringKeyVar" custom:"string_key_var"`
+}
+
+func TestCustomMarshaller_MapKeys(t *testing.T) {
+	testCases := []struct {
+		Name           string
+		Input          any
+		ExpectedError  error
+		ExpectedOutput string
+	}{
+		{
+			Name: "map key struct",
+			Input: mapKeyStruct{
+				IntKeyVar:    map[int]string{10: "ten", 2: "two", -1: "minus one"},
+				Int8KeyVar:   map[int8]int{-128: 1},
+				UintKeyVar:   map[uint64]bool{18446744073709551615: true},
+				NamedKeyVar:  map[colorName]int{"red": 1, "blue": 2},
+				TextKeyVar:   map[netip.Addr]string{netip.MustParseAddr("10.0.0.1"): "a"},
+				StringKeyVar: map[string]string{"b": "b", "a": "a"},
+			},
+			ExpectedError: nil,
+			ExpectedOutput: `{"int_key_var":{"-1":"minus one","10":"ten","2":"two"},"int8_key_var":{"-128":1},"uint_key_var":{"18446744073709551615":true},"named_key_var":{"blue":2,"red":1},"text_key_var":{"10.0.0.1":"a"},"string_key_var":{"a":"a","b":"b"}}
+`,
+		},
+		{
+			Name:           "unsupported key",
+			Input:          map[float64]int{1.5: 1},
+			ExpectedError:  errors.New("failed to marshal (root): unsupported type: map[float64]int"),
+			ExpectedOutput: "",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+			b, err := m.Marshal(testCase.Input)
+			assertError(t, testCase.ExpectedError, err)
+			assert.Equal(t, testCase.ExpectedOutput, string(b))
+
+			if err == nil {
+				var output mapKeyStruct
+				assert.NoError(t, m.Unmarshal(b, &output))
+				assert.Equal(t, testCase.Input, output)
+			}
+		})
+	}
+}
+
+func TestCustomMarshaller_UnmarshalMapKeys(t *testing.T) {
+	testCases := []struct {
+		Name          string
+		Input         string
+		ExpectedError error
+	}{
+		{
+			Name:          "overflow",
+			Input:         `{"int8_key_var":{"128":1}}`,
+			ExpectedError: errors.New(`failed to unmarshal int8_key_var: cannot unmarshal object key "128" into int8`),
+		},
+		{
+			Name:          "negative unsigned",
+			Input:         `{"uint_key_var":{"-1":true}}`,
+			ExpectedError: errors.New(`failed to unmarshal uint_key_var: cannot unmarshal object key "-1" into uint64`),
+		},
+		{
+			Name:          "invalid text",
+			Input:         `{"text_key_var":{"a":"a"}}`,
+			ExpectedError: errors.New(`failed to unmarshal text_key_var: failed to call UnmarshalText for map key type netip.Addr: ParseAddr("a"): unable to parse IP`),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			var output mapKeyStruct
+			err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).Unmarshal([]byte(testCase.Input), &output)
+			assertError(t, testCase.ExpectedError, err)
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package structTags
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// CustomTagMarshaler is implemented by types which marshal themselves into
+// valid JSON, depending on the target tag in use. It takes priority over
+// json.Marshaler and encoding.TextMarshaler.
+type CustomTagMarshaler interface {
+	MarshalCustomTag(tag string) ([]byte, error)
+}
+
+// CustomTagUnmarshaler is implemented by types which unmarshal a JSON
+// description of themselves, depending on the target tag in use. It takes
+// priority over json.Unmarshaler and encoding.TextUnmarshaler.
+type CustomTagUnmarshaler interface {
+	UnmarshalCustomTag(tag string, data []byte) error
+}
+
+var (
+	customTagMarshalerType   = reflect.TypeOf((*CustomTagMarshaler)(nil)).Elem()
+	jsonMarshalerType        = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType        = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	customTagUnmarshalerType = reflect.TypeOf((*CustomTagUnmarshaler)(nil)).Elem()
+	jsonUnmarshalerType      = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType      = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// implementer returns v, or its address when only the pointer type has the
+// method set, as an interface implementing one of the provided types. Like
+// encoding/json, interface values are left to be unwrapped first, and pointer
+// methods are only used on addressable values.
+func implementer(v reflect.Value, types ...reflect.Type) (interface{}, bool) {
+	if v.Kind() == reflect.Interface || !v.CanInterface() {
+		return nil, false
+	}
+	for _, t := range types {
+		if v.Type().Implements(t) {
+			return v.Interface(), true
+		}
+	}
+	if v.Kind() != reflect.Ptr && v.CanAddr() {
+		for _, t := range types {
+			if reflect.PtrTo(v.Type()).Implements(t) {
+				return v.Addr().Interface(), true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// isMarshaler reports whether v marshals itself.
+func isMarshaler(v reflect.Value) bool {
+	_, ok := implementer(v, customTagMarshalerType, jsonMarshalerType, textMarshalerType)
+	return ok
+}
+
+// hasMarshalerMethods reports whether values of type t, or pointers to them,
+// marshal themselves.
+func hasMarshalerMethods(t reflect.Type) bool {
+	for _, marshalerType := range []reflect.Type{customTagMarshalerType, jsonMarshalerType, textMarshalerType} {
+		if t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// marshalMarshaler reports the encoding which v produces itself. Nil pointers
+// are reported as null, without calling their methods.
+func (m *CustomMarshaller) marshalMarshaler(w *encodeState, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return w.format.Scalar(reflect.Value{})
+	}
+
+	i, _ := implementer(v, customTagMarshalerType, jsonMarshalerType, textMarshalerType)
+	switch marshaler := i.(type) {
+	case CustomTagMarshaler:
+		b, err := marshaler.MarshalCustomTag(m.TargetTag)
+		if err != nil {
+			return fmt.Errorf("failed to call MarshalCustomTag for type %s: %w", v.Type(), err)
+		}
+		return writeCompact(w, b, v.Type())
+	case json.Marshaler:
+		b, err := marshaler.MarshalJSON()
+		if err != nil {
+			return fmt.Errorf("failed to call MarshalJSON for type %s: %w", v.Type(), err)
+		}
+		return writeCompact(w, b, v.Type())
+	case encoding.TextMarshaler:
+		b, err := marshaler.MarshalText()
+		if err != nil {
+			return fmt.Errorf("failed to call MarshalText for type %s: %w", v.Type(), err)
+		}
+		return w.format.Scalar(reflect.ValueOf(string(b)))
+	}
+
+	return nil
+}
+
+// writeCompact validates the JSON produced by a marshaler of type t, and
+// reports it without insignificant whitespace.
+func writeCompact(w *encodeState, b []byte, t reflect.Type) error {
+	compact := bytes.NewBuffer(make([]byte, 0, len(b)))
+	err := json.Compact(compact, b)
+	if err != nil {
+		return fmt.Errorf("marshaler for type %s produced invalid JSON: %w", t, err)
+	}
+
+	return w.writeJSON(compact.Bytes())
+}
+
+// unmarshaler returns v as an interface implementing one of the unmarshaler
+// interfaces, if it does.
+func unmarshaler(v reflect.Value) (interface{}, bool) {
+	return implementer(v, customTagUnmarshalerType, jsonUnmarshalerType, textUnmarshalerType)
+}
+
+// unmarshalUnmarshaler hands data over to the unmarshaler u.
+func (m *CustomMarshaller) unmarshalUnmarshaler(data []byte, v reflect.Value, u interface{}) error {
+	switch unmarshaler := u.(type) {
+	case CustomTagUnmarshaler:
+		return unmarshaler.UnmarshalCustomTag(m.TargetTag, data)
+	case json.Unmarshaler:
+		return unmarshaler.UnmarshalJSON(data)
+	case encoding.TextUnmarshaler:
+		if data[0] != '"' {
+			return unmarshalTypeError(data, v)
+		}
+		var s string
+		err := json.Unmarshal(data, &s)
+		if err != nil {
+			return err
+		}
+		return unmarshaler.UnmarshalText([]byte(s))
+	}
+
+	return nil
+}
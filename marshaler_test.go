@@ -0,0 +1,124 @@
+package structTags
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tagAware renders itself as the name of the target tag in use.
+type tagAware struct {
+	Tag string
+}
+
+func (a tagAware) MarshalCustomTag(tag string) ([]byte, error) {
+	return []byte(fmt.Sprintf("%q", tag)), nil
+}
+
+func (a *tagAware) UnmarshalCustomTag(tag string, data []byte) error {
+	a.Tag = tag + "=" + string(data)
+	return nil
+}
+
+// spacedJSON produces JSON with insignificant whitespace.
+type spacedJSON struct{}
+
+func (s spacedJSON) MarshalJSON() ([]byte, error) {
+	return []byte(`{ "a" : [ 1, 2 ] }`), nil
+}
+
+// brokenJSON produces invalid JSON.
+type brokenJSON struct{}
+
+func (b brokenJSON) MarshalJSON() ([]byte, error) {
+	return []byte(`{`), nil
+}
+
+// failingJSON fails to marshal.
+type failingJSON struct{}
+
+func (f failingJSON) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("failing")
+}
+
+// upperText is a text marshaler with a pointer receiver for unmarshalling.
+type upperText string
+
+func (u upperText) MarshalText() ([]byte, error) {
+	return []byte(strings.ToUpper(string(u))), nil
+}
+
+func (u *upperText) UnmarshalText(text []byte) error {
+	*u = upperText(strings.ToLower(string(text)))
+	return nil
+}
+
+type marshalerStruct struct {
+	TimeVar     time.Time  `json:"timeVar" custom:"time_var"`
+	TimePtrVar  *time.Time `json:"timePtrVar" custom:"time_ptr_var"`
+	TagAwareVar tagAware   `json:"tagAwareVar" custom:"tag_aware_var"`
+	SpacedVar   spacedJSON `json:"spacedVar" custom:"spaced_var"`
+	TextVar     upperText  `json:"textVar" custom:"text_var"`
+	AnyVar      any        `json:"anyVar" custom:"any_var"`
+}
+
+func TestCustomMarshaller_MarshalMarshalers(t *testing.T) {
+	testCases := []struct {
+		Name           string
+		Input          any
+		ExpectedError  error
+		ExpectedOutput string
+	}{
+		{
+			Name: "marshaler struct",
+			Input: marshalerStruct{
+				TimeVar:     time.Date(2024, 5, 24, 12, 0, 0, 0, time.UTC),
+				TimePtrVar:  nil,
+				TagAwareVar: tagAware{},
+				SpacedVar:   spacedJSON{},
+				TextVar:     "text",
+				AnyVar:      upperText("any"),
+			},
+			ExpectedError: nil,
+			ExpectedOutput: `{"time_var":"2024-05-24T12:00:00Z","time_ptr_var":null,"tag_aware_var":"custom","spaced_var":{"a":[1,2]},"text_var":"TEXT","any_var":"ANY"}
+`,
+		},
+		{
+			Name:           "invalid JSON",
+			Input:          brokenJSON{},
+			ExpectedError:  errors.New("failed to marshal (root): marshaler for type structTags.brokenJSON produced invalid JSON: unexpected end of JSON input"),
+			ExpectedOutput: "",
+		},
+		{
+			Name:           "marshaler error",
+			Input:          []failingJSON{{}},
+			ExpectedError:  errors.New("failed to marshal [0]: failed to call MarshalJSON for type structTags.failingJSON: failing"),
+			ExpectedOutput: "",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			b, err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).Marshal(testCase.Input)
+			assertError(t, testCase.ExpectedError, err)
+			assert.Equal(t, testCase.ExpectedOutput, string(b))
+		})
+	}
+}
+
+func TestCustomMarshaller_UnmarshalUnmarshalers(t *testing.T) {
+	var output marshalerStruct
+	err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).Unmarshal([]byte(`{"time_var":"2024-05-24T12:00:00Z","time_ptr_var":"2024-05-25T12:00:00Z","tag_aware_var":[1],"text_var":"TEXT"}`), &output)
+	assert.NoError(t, err)
+
+	timePtr := time.Date(2024, 5, 25, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, marshalerStruct{
+		TimeVar:     time.Date(2024, 5, 24, 12, 0, 0, 0, time.UTC),
+		TimePtrVar:  &timePtr,
+		TagAwareVar: tagAware{Tag: "custom=[1]"},
+		TextVar:     "text",
+	}, output)
+}
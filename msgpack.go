@@ -0,0 +1,384 @@
+package structTags
+
+import (
+	"math"
+	"reflect"
+)
+
+// MarshalMsgpack is like Marshal, but encodes obj as MessagePack, keyed by the
+// same target tag names.
+//
+// Integers and floats keep their kinds, using the smallest encoding which
+// holds them, and byte slices without a bytes tag option are encoded as
+// binary. Values of types registered with RegisterMsgpackExtension become
+// extension types. With MsgpackStructsAsArrays, structs are encoded as arrays
+// holding every field in order, rather than as maps.
+func (m *CustomMarshaller) MarshalMsgpack(obj interface{}) ([]byte, error) {
+	f := &msgpackFormat{m: m}
+	var format Format = f
+	if m.MsgpackStructsAsArrays {
+		format = &msgpackArrayFormat{f}
+	}
+	err := m.MarshalFormat(format, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.b, nil
+}
+
+// MsgpackExtensionEncoderFunc returns the payload of the MessagePack extension
+// type encoding v.
+type MsgpackExtensionEncoderFunc func(v reflect.Value) ([]byte, error)
+
+// MsgpackExtensionDecoderFunc decodes the payload of a MessagePack extension
+// type into v, which is settable.
+type MsgpackExtensionDecoderFunc func(data []byte, v reflect.Value) error
+
+// msgpackExtension is an extension type registered for a Go type.
+type msgpackExtension struct {
+	code   int8
+	encode MsgpackExtensionEncoderFunc
+	decode MsgpackExtensionDecoderFunc
+}
+
+// RegisterMsgpackExtension makes values of type t marshal to MessagePack as
+// the extension type code, with the payload returned by encode, wherever
+// they are found. Unmarshalling the extension type into t, or into an empty
+// interface, calls decode. When several types share a code, unmarshalling it
+// into an empty interface uses the most recently registered of them. Negative codes are
+// reserved by the MessagePack specification, e.g. -1 for timestamps.
+//
+// Registering is not safe to do concurrently with marshalling.
+func (m *CustomMarshaller) RegisterMsgpackExtension(t reflect.Type, code int8, encode func(v reflect.Value) ([]byte, error), decode func(data []byte, v reflect.Value) error) {
+	if m.extensions == nil {
+		m.extensions = map[reflect.Type]msgpackExtension{}
+		m.extensionTypes = map[int8][]reflect.Type{}
+	}
+	if previous, ok := m.extensions[t]; ok {
+		m.removeExtensionType(previous.code, t)
+	}
+	m.extensions[t] = msgpackExtension{
+		code:   code,
+		encode: encode,
+		decode: decode,
+	}
+	m.extensionTypes[code] = append(m.extensionTypes[code], t)
+}
+
+// removeExtensionType removes t from the types registered for code, so that
+// the code decodes into the most recent remaining registration.
+func (m *CustomMarshaller) removeExtensionType(code int8, t reflect.Type) {
+	types := m.extensionTypes[code]
+	for i := range types {
+		if types[i] == t {
+			types = append(types[:i:i], types[i+1:]...)
+			break
+		}
+	}
+	if len(types) == 0 {
+		delete(m.extensionTypes, code)
+		return
+	}
+	m.extensionTypes[code] = types
+}
+
+// extensionType returns the type which the extension type code decodes into
+// when unmarshalling into an empty interface.
+func (m *CustomMarshaller) extensionType(code int8) (reflect.Type, bool) {
+	types := m.extensionTypes[code]
+	if len(types) == 0 {
+		return nil, false
+	}
+
+	return types[len(types)-1], true
+}
+
+// extensionFormat returns the format of the walk, when it encodes extension
+// types and values of type t are registered as one.
+func (m *CustomMarshaller) extensionFormat(w *encodeState, t reflect.Type) (ExtensionFormat, msgpackExtension, bool) {
+	if len(m.extensions) == 0 {
+		return nil, msgpackExtension{}, false
+	}
+	f, ok := w.format.(ExtensionFormat)
+	if !ok {
+		return nil, msgpackExtension{}, false
+	}
+	ext, ok := m.extensions[t]
+
+	return f, ext, ok
+}
+
+// msgpackContainer is a map or array being written.
+type msgpackContainer struct {
+	// start is the offset of the header, which is written once the number of
+	// entries is known.
+	start  int
+	count  int
+	object bool
+}
+
+// msgpackHeaderSpace is the space reserved for the header of each map and
+// array, which fits the largest header.
+const msgpackHeaderSpace = 5
+
+// msgpackFormat is the Format of MarshalMsgpack.
+type msgpackFormat struct {
+	m    *CustomMarshaller
+	b    []byte
+	open []msgpackContainer
+}
+
+// element counts a value about to be written, when it's an array element.
+func (f *msgpackFormat) element() {
+	if len(f.open) > 0 && !f.open[len(f.open)-1].object {
+		f.open[len(f.open)-1].count++
+	}
+}
+
+func (f *msgpackFormat) begin(object bool) error {
+	f.element()
+	f.open = append(f.open, msgpackContainer{
+		start:  len(f.b),
+		object: object,
+	})
+	f.b = append(f.b, make([]byte, msgpackHeaderSpace)...)
+	return nil
+}
+
+// end writes the header of the innermost container, moving its entries back
+// over the unused space.
+func (f *msgpackFormat) end() error {
+	c := f.open[len(f.open)-1]
+	f.open = f.open[:len(f.open)-1]
+
+	var scratch [msgpackHeaderSpace]byte
+	var header []byte
+	if c.object {
+		header = appendMsgpackLength(scratch[:0], c.count, 0x80, 16, 0xde)
+	} else {
+		header = appendMsgpackLength(scratch[:0], c.count, 0x90, 16, 0xdc)
+	}
+	body := len(f.b) - c.start - msgpackHeaderSpace
+	copy(f.b[c.start+len(header):], f.b[c.start+msgpackHeaderSpace:])
+	f.b = f.b[:c.start+len(header)+body]
+	copy(f.b[c.start:], header)
+	return nil
+}
+
+func (f *msgpackFormat) BeginObject() error {
+	return f.begin(true)
+}
+
+func (f *msgpackFormat) Key(key string) error {
+	key, err := f.m.validUTF8(key)
+	if err != nil {
+		return err
+	}
+	f.open[len(f.open)-1].count++
+	f.b = appendMsgpackString(f.b, key)
+	return nil
+}
+
+func (f *msgpackFormat) EndObject() error {
+	return f.end()
+}
+
+func (f *msgpackFormat) BeginArray() error {
+	return f.begin(false)
+}
+
+func (f *msgpackFormat) EndArray() error {
+	return f.end()
+}
+
+func (f *msgpackFormat) Scalar(v reflect.Value) error {
+	f.element()
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		f.b = append(f.b, 0xc0)
+	case reflect.Bool:
+		if v.Bool() {
+			f.b = append(f.b, 0xc3)
+		} else {
+			f.b = append(f.b, 0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f.b = appendMsgpackInt(f.b, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		f.b = appendMsgpackUint(f.b, v.Uint())
+	case reflect.Float32:
+		f.b = append(f.b, 0xca)
+		f.b = appendUint32(f.b, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		f.b = append(f.b, 0xcb)
+		f.b = appendUint64(f.b, math.Float64bits(v.Float()))
+	case reflect.String:
+		s, err := f.m.validUTF8(v.String())
+		if err != nil {
+			return err
+		}
+		f.b = appendMsgpackString(f.b, s)
+	default:
+		return &UnsupportedTypeError{Type: v.Type()}
+	}
+
+	return nil
+}
+
+// Binary writes b as binary.
+func (f *msgpackFormat) Binary(b []byte) error {
+	f.element()
+	switch {
+	case len(b) <= math.MaxUint8:
+		f.b = append(f.b, 0xc4, byte(len(b)))
+	case len(b) <= math.MaxUint16:
+		f.b = append(f.b, 0xc5)
+		f.b = appendUint16(f.b, uint16(len(b)))
+	default:
+		f.b = append(f.b, 0xc6)
+		f.b = appendUint32(f.b, uint32(len(b)))
+	}
+	f.b = append(f.b, b...)
+	return nil
+}
+
+// Extension writes data as the payload of the extension type code.
+func (f *msgpackFormat) Extension(code int8, data []byte) error {
+	f.element()
+	switch len(data) {
+	case 1:
+		f.b = append(f.b, 0xd4)
+	case 2:
+		f.b = append(f.b, 0xd5)
+	case 4:
+		f.b = append(f.b, 0xd6)
+	case 8:
+		f.b = append(f.b, 0xd7)
+	case 16:
+		f.b = append(f.b, 0xd8)
+	default:
+		switch {
+		case len(data) <= math.MaxUint8:
+			f.b = append(f.b, 0xc7, byte(len(data)))
+		case len(data) <= math.MaxUint16:
+			f.b = append(f.b, 0xc8)
+			f.b = appendUint16(f.b, uint16(len(data)))
+		default:
+			f.b = append(f.b, 0xc9)
+			f.b = appendUint32(f.b, uint32(len(data)))
+		}
+	}
+	f.b = append(f.b, byte(code))
+	f.b = append(f.b, data...)
+
+	return nil
+}
+
+// msgpackArrayFormat is the Format of MarshalMsgpack with
+// MsgpackStructsAsArrays, which writes structs as arrays of their fields.
+type msgpackArrayFormat struct {
+	*msgpackFormat
+}
+
+// Struct writes the struct as an array of its fields. Omitted fields are
+// written as nil, so that each field keeps its position.
+func (f *msgpackArrayFormat) Struct(s *StructWriter) error {
+	err := f.BeginArray()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < s.NumField(); i++ {
+		if s.Field(i).Omitted {
+			err = f.Scalar(reflect.Value{})
+		} else {
+			err = s.WriteField(i)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return f.EndArray()
+}
+
+// appendMsgpackLength appends the header of a map, array or string of n
+// entries. fixed is the first byte of the fixed-size header, which holds
+// lengths below fixedLimit, and code16 is the byte of the 16-bit header,
+// followed by that of the 32-bit header.
+func appendMsgpackLength(b []byte, n int, fixed byte, fixedLimit int, code16 byte) []byte {
+	switch {
+	case n < fixedLimit:
+		return append(b, fixed|byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, code16)
+		return appendUint16(b, uint16(n))
+	}
+	b = append(b, code16+1)
+
+	return appendUint32(b, uint32(n))
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	if len(s) >= 32 && len(s) <= math.MaxUint8 {
+		b = append(b, 0xd9, byte(len(s)))
+	} else {
+		b = appendMsgpackLength(b, len(s), 0xa0, 32, 0xda)
+	}
+
+	return append(b, s...)
+}
+
+// appendMsgpackInt appends n using the smallest encoding which holds it.
+// Non-negative integers are encoded as unsigned.
+func appendMsgpackInt(b []byte, n int64) []byte {
+	switch {
+	case n >= 0:
+		return appendMsgpackUint(b, uint64(n))
+	case n >= -32:
+		return append(b, byte(n))
+	case n >= math.MinInt8:
+		return append(b, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		b = append(b, 0xd1)
+		return appendUint16(b, uint16(n))
+	case n >= math.MinInt32:
+		b = append(b, 0xd2)
+		return appendUint32(b, uint32(n))
+	}
+	b = append(b, 0xd3)
+
+	return appendUint64(b, uint64(n))
+}
+
+// appendMsgpackUint appends n using the smallest encoding which holds it.
+func appendMsgpackUint(b []byte, n uint64) []byte {
+	switch {
+	case n < 128:
+		return append(b, byte(n))
+	case n <= math.MaxUint8:
+		return append(b, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, 0xcd)
+		return appendUint16(b, uint16(n))
+	case n <= math.MaxUint32:
+		b = append(b, 0xce)
+		return appendUint32(b, uint32(n))
+	}
+	b = append(b, 0xcf)
+
+	return appendUint64(b, n)
+}
+
+func appendUint16(b []byte, n uint16) []byte {
+	return append(b, byte(n>>8), byte(n))
+}
+
+func appendUint32(b []byte, n uint32) []byte {
+	return append(b, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+func appendUint64(b []byte, n uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(n>>32)), uint32(n))
+}
@@ -0,0 +1,408 @@
+package structTags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type msgpackPoint struct {
+	X int32 `custom:"x"`
+	Y int32 `custom:"y"`
+}
+
+type msgpackItem struct {
+	Name    string             `custom:"name"`
+	Ignored string             `custom:"-"`
+	Count   uint16             `custom:"count"`
+	Delta   int64              `custom:"delta"`
+	Ratio   float32            `custom:"ratio"`
+	Score   float64            `custom:"score"`
+	OK      bool               `custom:"ok"`
+	Raw     []byte             `custom:"raw"`
+	Hex     []byte             `custom:"hex,hex"`
+	Quoted  int                `custom:"quoted,string"`
+	Tags    []string           `custom:"tags"`
+	Fixed   [2]int8            `custom:"fixed"`
+	Attrs   map[int]string     `custom:"attrs"`
+	Child   *msgpackPoint      `custom:"child"`
+	Points  []msgpackPoint     `custom:"points"`
+	Any     interface{}        `custom:"any"`
+	When    time.Time          `custom:"when"`
+	Wave    complex128         `custom:"wave"`
+	Missing *msgpackPoint      `custom:"missing,omitempty"`
+	Nested  map[string][]uint8 `custom:"nested"`
+}
+
+// registerMsgpackPoint registers msgpackPoint as the extension type 7, packing
+// both coordinates into 8 bytes.
+func registerMsgpackPoint(m *CustomMarshaller) {
+	m.RegisterMsgpackExtension(reflect.TypeOf(msgpackPoint{}), 7,
+		func(v reflect.Value) ([]byte, error) {
+			p := v.Interface().(msgpackPoint)
+			b := make([]byte, 8)
+			binary.BigEndian.PutUint32(b, uint32(p.X))
+			binary.BigEndian.PutUint32(b[4:], uint32(p.Y))
+			return b, nil
+		},
+		func(data []byte, v reflect.Value) error {
+			if len(data) != 8 {
+				return errors.New("point: wrong size")
+			}
+			v.Set(reflect.ValueOf(msgpackPoint{
+				X: int32(binary.BigEndian.Uint32(data)),
+				Y: int32(binary.BigEndian.Uint32(data[4:])),
+			}))
+			return nil
+		})
+}
+
+func TestCustomMarshaller_MarshalMsgpack(t *testing.T) {
+	testCases := []struct {
+		Name           string
+		Input          any
+		Configure      func(m *CustomMarshaller)
+		ExpectedError  error
+		ExpectedOutput []byte
+	}{
+		{
+			Name:           "struct",
+			Input:          msgpackPoint{X: 1, Y: -1},
+			ExpectedOutput: []byte{0x82, 0xa1, 'x', 0x01, 0xa1, 'y', 0xff},
+		},
+		{
+			Name:  "struct as array",
+			Input: &msgpackPoint{X: 200, Y: -200},
+			Configure: func(m *CustomMarshaller) {
+				m.MsgpackStructsAsArrays = true
+			},
+			ExpectedOutput: []byte{0x92, 0xcc, 200, 0xd1, 0xff, 0x38},
+		},
+		{
+			Name: "omitted fields keep their position in arrays",
+			Input: struct {
+				A *int `custom:"a,omitempty"`
+				B bool `custom:"b"`
+			}{B: true},
+			Configure: func(m *CustomMarshaller) {
+				m.MsgpackStructsAsArrays = true
+			},
+			ExpectedOutput: []byte{0x92, 0xc0, 0xc3},
+		},
+		{
+			Name:  "integers use the smallest encoding",
+			Input: []interface{}{-33, 1 << 16, uint64(math.MaxUint64), int64(math.MinInt64)},
+			ExpectedOutput: []byte{
+				0x94,
+				0xd0, 0xdf,
+				0xce, 0x00, 0x01, 0x00, 0x00,
+				0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0xd3, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			},
+		},
+		{
+			Name:           "floats keep their size",
+			Input:          []interface{}{float32(1.5), math.Inf(1)},
+			ExpectedOutput: []byte{0x92, 0xca, 0x3f, 0xc0, 0x00, 0x00, 0xcb, 0x7f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			Name:           "binary and strings",
+			Input:          []interface{}{[]byte{1, 2}, strings.Repeat("a", 32), nil},
+			ExpectedOutput: append(append([]byte{0x93, 0xc4, 0x02, 0x01, 0x02, 0xd9, 32}, strings.Repeat("a", 32)...), 0xc0),
+		},
+		{
+			Name:           "large arrays",
+			Input:          make([]bool, 16),
+			ExpectedOutput: append([]byte{0xdc, 0x00, 0x10}, bytes.Repeat([]byte{0xc2}, 16)...),
+		},
+		{
+			Name:           "extension types",
+			Input:          map[string]msgpackPoint{"p": {X: 1, Y: 2}},
+			Configure:      registerMsgpackPoint,
+			ExpectedOutput: []byte{0x81, 0xa1, 'p', 0xd7, 0x07, 0, 0, 0, 1, 0, 0, 0, 2},
+		},
+		{
+			Name:          "unsupported values",
+			Input:         struct{ C chan int }{},
+			ExpectedError: errors.New("failed to marshal C: unsupported type: chan int"),
+		},
+		{
+			Name:           "nil",
+			Input:          nil,
+			ExpectedOutput: []byte{0xc0},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+			if testCase.Configure != nil {
+				testCase.Configure(m)
+			}
+			output, err := m.MarshalMsgpack(testCase.Input)
+			assertError(t, testCase.ExpectedError, err)
+			assert.Equal(t, testCase.ExpectedOutput, output)
+		})
+	}
+}
+
+func TestCustomMarshaller_MsgpackRoundTrip(t *testing.T) {
+	input := msgpackItem{
+		Name:    strings.Repeat("long ", 60),
+		Ignored: "ignored",
+		Count:   math.MaxUint16,
+		Delta:   math.MinInt32 - 1,
+		Ratio:   0.25,
+		Score:   math.Inf(-1),
+		OK:      true,
+		Raw:     make([]byte, 300),
+		Hex:     []byte{0xca, 0xfe},
+		Quoted:  -12,
+		Tags:    []string{"a", ""},
+		Fixed:   [2]int8{-128, 127},
+		Attrs:   map[int]string{-1: "minus one", 2: "two"},
+		Child:   &msgpackPoint{X: 3},
+		Points:  []msgpackPoint{{X: 1, Y: 2}, {}},
+		Any:     map[string]interface{}{"list": []interface{}{1.5, "s", nil, true}},
+		When:    time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+		Wave:    complex(1, -2),
+		Nested:  map[string][]uint8{"k": {1}},
+	}
+	expected := input
+	expected.Ignored = ""
+
+	for _, asArrays := range []bool{false, true} {
+		m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+		m.ComplexEncoding = ComplexAsArray
+		m.MsgpackStructsAsArrays = asArrays
+		data, err := m.MarshalMsgpack(input)
+		assert.NoError(t, err)
+
+		var output msgpackItem
+		err = m.UnmarshalMsgpack(data, &output)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, output)
+	}
+}
+
+func TestCustomMarshaller_MsgpackExtensions(t *testing.T) {
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	registerMsgpackPoint(m)
+
+	input := map[string]interface{}{
+		"point":  msgpackPoint{X: -5, Y: 6},
+		"points": []*msgpackPoint{{X: 1}, nil},
+	}
+	data, err := m.MarshalMsgpack(input)
+	assert.NoError(t, err)
+
+	var output struct {
+		Point  msgpackPoint    `custom:"point"`
+		Points []*msgpackPoint `custom:"points"`
+	}
+	err = m.UnmarshalMsgpack(data, &output)
+	assert.NoError(t, err)
+	assert.Equal(t, msgpackPoint{X: -5, Y: 6}, output.Point)
+	assert.Equal(t, []*msgpackPoint{{X: 1}, nil}, output.Points)
+
+	var generic interface{}
+	err = m.UnmarshalMsgpack(data, &generic)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"point":  msgpackPoint{X: -5, Y: 6},
+		"points": []interface{}{msgpackPoint{X: 1}, nil},
+	}, generic)
+
+	err = NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).UnmarshalMsgpack(data, &generic)
+	assert.EqualError(t, err, "failed to unmarshal (root): cannot unmarshal unregistered extension type 7")
+}
+
+type msgpackFirst struct{}
+
+type msgpackSecond struct{}
+
+func TestCustomMarshaller_MsgpackExtensionCodes(t *testing.T) {
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	register := func(t reflect.Type, code int8) {
+		m.RegisterMsgpackExtension(t, code,
+			func(v reflect.Value) ([]byte, error) {
+				return nil, nil
+			},
+			func(data []byte, v reflect.Value) error {
+				return nil
+			})
+	}
+	decode := func(code byte) (interface{}, error) {
+		var output interface{}
+		err := m.UnmarshalMsgpack([]byte{0xc7, 0x00, code}, &output)
+		return output, err
+	}
+
+	register(reflect.TypeOf(msgpackFirst{}), 1)
+	register(reflect.TypeOf(msgpackSecond{}), 1)
+	// Types sharing a code used to be picked in map iteration order, so repeat
+	// the decoding.
+	for i := 0; i < 100; i++ {
+		output, err := decode(1)
+		assert.NoError(t, err)
+		assert.Equal(t, msgpackSecond{}, output)
+	}
+
+	register(reflect.TypeOf(msgpackSecond{}), 2)
+	output, err := decode(2)
+	assert.NoError(t, err)
+	assert.Equal(t, msgpackSecond{}, output)
+	// The code is still registered for msgpackFirst, which decodes it again.
+	output, err = decode(1)
+	assert.NoError(t, err)
+	assert.Equal(t, msgpackFirst{}, output)
+
+	data, err := m.MarshalMsgpack([]interface{}{msgpackFirst{}, msgpackSecond{}})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x92, 0xc7, 0x00, 0x01, 0xc7, 0x00, 0x02}, data)
+	var roundTrip interface{}
+	assert.NoError(t, m.UnmarshalMsgpack(data, &roundTrip))
+	assert.Equal(t, []interface{}{msgpackFirst{}, msgpackSecond{}}, roundTrip)
+
+	register(reflect.TypeOf(msgpackFirst{}), 2)
+	_, err = decode(1)
+	assert.EqualError(t, err, "failed to unmarshal (root): cannot unmarshal unregistered extension type 1")
+}
+
+func TestCustomMarshaller_UnmarshalMsgpack(t *testing.T) {
+	testCases := []struct {
+		Name           string
+		Input          []byte
+		Output         any
+		ExpectedError  error
+		ExpectedOutput any
+	}{
+		{
+			Name:           "map keys matched like Unmarshal",
+			Input:          []byte{0x83, 0xa1, 'X', 0x01, 0xa1, 'z', 0x02, 0x01, 0x03},
+			Output:         &msgpackPoint{},
+			ExpectedOutput: &msgpackPoint{X: 1},
+		},
+		{
+			Name:           "short arrays into structs",
+			Input:          []byte{0x91, 0x05},
+			Output:         &msgpackPoint{Y: 9},
+			ExpectedOutput: &msgpackPoint{X: 5, Y: 9},
+		},
+		{
+			Name:           "integers into floats",
+			Input:          []byte{0xd0, 0x80},
+			Output:         new(float64),
+			ExpectedOutput: func() *float64 { f := -128.0; return &f }(),
+		},
+		{
+			Name:           "strings into byte slices",
+			Input:          []byte{0xa4, 'a', 'G', 'k', '='},
+			Output:         new([]byte),
+			ExpectedOutput: &[]byte{'h', 'i'},
+		},
+		{
+			Name:   "generic values",
+			Input:  []byte{0x82, 0x01, 0xc4, 0x01, 0xff, 0xa1, 'n', 0xcc, 0xff},
+			Output: new(interface{}),
+			ExpectedOutput: func() *interface{} {
+				var x interface{} = map[string]interface{}{"1": []byte{0xff}, "n": 255.0}
+				return &x
+			}(),
+		},
+		{
+			Name:           "type mismatch",
+			Input:          []byte{0x81, 0xa1, 'y', 0xa1, 's'},
+			Output:         &msgpackPoint{},
+			ExpectedOutput: &msgpackPoint{},
+			ExpectedError:  errors.New("failed to unmarshal y: cannot unmarshal string into int32"),
+		},
+		{
+			Name:           "overflow",
+			Input:          []byte{0x91, 0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			Output:         &[]int8{},
+			ExpectedOutput: &[]int8{0},
+			ExpectedError:  errors.New("failed to unmarshal [0]: cannot unmarshal number 18446744073709551615 into int8"),
+		},
+		{
+			Name:           "too many fields",
+			Input:          []byte{0x93, 0x01, 0x02, 0x03},
+			Output:         &msgpackPoint{},
+			ExpectedOutput: &msgpackPoint{},
+			ExpectedError:  errors.New("failed to unmarshal (root): cannot unmarshal array of 3 elements into structTags.msgpackPoint with 2 fields"),
+		},
+		{
+			Name:           "unregistered extension types",
+			Input:          []byte{0xd4, 0x05, 0x00},
+			Output:         new(string),
+			ExpectedOutput: new(string),
+			ExpectedError:  errors.New("failed to unmarshal (root): cannot unmarshal extension type 5 into string"),
+		},
+		{
+			Name:           "truncated",
+			Input:          []byte{0x92, 0x01},
+			Output:         new(interface{}),
+			ExpectedOutput: new(interface{}),
+			ExpectedError:  &MsgpackSyntaxError{Offset: 2, Msg: "unexpected end of data"},
+		},
+		{
+			Name:           "huge lengths",
+			Input:          []byte{0xdd, 0xff, 0xff, 0xff, 0xff},
+			Output:         new(interface{}),
+			ExpectedOutput: new(interface{}),
+			ExpectedError:  &MsgpackSyntaxError{Offset: 5, Msg: "length 4294967295 exceeds the remaining data"},
+		},
+		{
+			Name:           "invalid type byte",
+			Input:          []byte{0x91, 0xc1},
+			Output:         new(interface{}),
+			ExpectedOutput: new(interface{}),
+			ExpectedError:  &MsgpackSyntaxError{Offset: 1, Msg: "invalid type byte 0xc1"},
+		},
+		{
+			Name:           "trailing data",
+			Input:          []byte{0xc0, 0xc0},
+			Output:         new(interface{}),
+			ExpectedOutput: new(interface{}),
+			ExpectedError:  &MsgpackSyntaxError{Offset: 1, Msg: "unexpected data after the value"},
+		},
+		{
+			Name:           "non-pointer",
+			Input:          []byte{0xc0},
+			Output:         msgpackPoint{},
+			ExpectedOutput: msgpackPoint{},
+			ExpectedError:  ErrNonPointer,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).UnmarshalMsgpack(testCase.Input, testCase.Output)
+			assertError(t, testCase.ExpectedError, err)
+			assert.Equal(t, testCase.ExpectedOutput, testCase.Output)
+		})
+	}
+}
+
+func TestCustomMarshaller_UnmarshalMsgpack_MaxDepth(t *testing.T) {
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	m.MaxDepth = 2
+
+	var output interface{}
+	err := m.UnmarshalMsgpack([]byte{0x91, 0x81, 0xa1, 'k', 0x91, 0xc0}, &output)
+	var depthErr *MaxDepthError
+	assert.True(t, errors.As(err, &depthErr))
+	assert.Equal(t, `[0]["k"]`, depthErr.Path)
+
+	data, err := m.MarshalMsgpack([][]int{{1}})
+	assert.NoError(t, err)
+	err = m.UnmarshalMsgpack(data, &output)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{[]interface{}{1.0}}, output)
+}
@@ -0,0 +1,668 @@
+package structTags
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// UnmarshalMsgpack parses the MessagePack-encoded data and stores the result
+// in the value pointed to by obj, matching map keys to struct fields like
+// Unmarshal. Structs also accept arrays holding their fields in order, as
+// written with MsgpackStructsAsArrays.
+//
+// Binary is decoded into byte slices, and extension types into the types
+// they're registered for with RegisterMsgpackExtension. Anything else is
+// decoded the way Unmarshal decodes the equivalent JSON, so that marshaler
+// methods and registered decoders apply as usual.
+func (m *CustomMarshaller) UnmarshalMsgpack(data []byte, obj interface{}) error {
+	v, err := unmarshalTarget(obj)
+	if err != nil {
+		return err
+	}
+
+	d := m.newDecodeState()
+	x, err := parseMsgpack(data, d.maxDepth)
+	if err != nil {
+		return err
+	}
+	err = m.unmarshalMsgpack(d, x, v)
+	if err != nil {
+		return d.wrap(v.Type(), err)
+	}
+
+	return nil
+}
+
+// MsgpackSyntaxError describes malformed MessagePack.
+type MsgpackSyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *MsgpackSyntaxError) Error() string {
+	return fmt.Sprintf("msgpack: offset %d: %s", e.Offset, e.Msg)
+}
+
+// msgpackMap is a decoded map, whose keys may be of any type.
+type msgpackMap struct {
+	keys   []interface{}
+	values []interface{}
+}
+
+// msgpackExt is a decoded extension type.
+type msgpackExt struct {
+	code int8
+	data []byte
+}
+
+// msgpackParser parses MessagePack into nil, bools, int64s, uint64s,
+// float64s, strings, []byte for binary, msgpackExts, []interface{} and
+// *msgpackMaps.
+type msgpackParser struct {
+	data     []byte
+	pos      int
+	maxDepth int
+	depth    int
+	path     path
+}
+
+// parseMsgpack parses the single MessagePack value held by data. Maps and
+// arrays may be nested up to maxDepth levels deep, unless it's negative.
+func parseMsgpack(data []byte, maxDepth int) (interface{}, error) {
+	p := &msgpackParser{
+		data:     data,
+		maxDepth: maxDepth,
+	}
+	x, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.data) {
+		return nil, p.errorf("unexpected data after the value")
+	}
+
+	return x, nil
+}
+
+func (p *msgpackParser) errorf(format string, args ...interface{}) error {
+	return &MsgpackSyntaxError{
+		Offset: p.pos,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+// next returns the next n bytes.
+func (p *msgpackParser) next(n int) ([]byte, error) {
+	if n < 0 || len(p.data)-p.pos < n {
+		return nil, p.errorf("unexpected end of data")
+	}
+	b := p.data[p.pos : p.pos+n]
+	p.pos += n
+
+	return b, nil
+}
+
+// uint reads a big-endian unsigned integer of n bytes.
+func (p *msgpackParser) uint(n int) (uint64, error) {
+	b, err := p.next(n)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+
+	return u, nil
+}
+
+// length reads a length of n bytes, checking that at least as many entries
+// of min bytes each remain, so that huge lengths don't allocate.
+func (p *msgpackParser) length(n, min int) (int, error) {
+	u, err := p.uint(n)
+	if err != nil {
+		return 0, err
+	}
+	if u > uint64(len(p.data)-p.pos)/uint64(min) {
+		return 0, p.errorf("length %d exceeds the remaining data", u)
+	}
+
+	return int(u), nil
+}
+
+func (p *msgpackParser) parse() (interface{}, error) {
+	b, err := p.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c <= 0x8f:
+		return p.parseMap(int(c & 0x0f))
+	case c <= 0x9f:
+		return p.parseArray(int(c & 0x0f))
+	case c <= 0xbf:
+		return p.parseString(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := p.length(1<<(c-0xc4), 1)
+		if err != nil {
+			return nil, err
+		}
+		b, err := p.next(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, b...), nil
+	case 0xc7, 0xc8, 0xc9:
+		n, err := p.length(1<<(c-0xc7), 1)
+		if err != nil {
+			return nil, err
+		}
+		return p.parseExt(n)
+	case 0xca:
+		u, err := p.uint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := p.uint(8)
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return p.uint(1 << (c - 0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		u, err := p.uint(size)
+		// Sign-extend the integer from its size.
+		shift := 64 - 8*size
+		return int64(u<<shift) >> shift, err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return p.parseExt(1 << (c - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := p.length(1<<(c-0xd9), 1)
+		if err != nil {
+			return nil, err
+		}
+		return p.parseString(n)
+	case 0xdc, 0xdd:
+		n, err := p.length(2<<(c-0xdc), 1)
+		if err != nil {
+			return nil, err
+		}
+		return p.parseArray(n)
+	case 0xde, 0xdf:
+		n, err := p.length(2<<(c-0xde), 2)
+		if err != nil {
+			return nil, err
+		}
+		return p.parseMap(n)
+	}
+
+	p.pos--
+	return nil, p.errorf("invalid type byte 0x%02x", c)
+}
+
+func (p *msgpackParser) parseString(n int) (interface{}, error) {
+	b, err := p.next(n)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+func (p *msgpackParser) parseExt(n int) (interface{}, error) {
+	code, err := p.next(1)
+	if err != nil {
+		return nil, err
+	}
+	b, err := p.next(n)
+	if err != nil {
+		return nil, err
+	}
+
+	return msgpackExt{
+		code: int8(code[0]),
+		data: append([]byte{}, b...),
+	}, nil
+}
+
+// enter records that the parser descends into a map or array.
+func (p *msgpackParser) enter() error {
+	p.depth++
+	if p.maxDepth > 0 && p.depth > p.maxDepth {
+		return &MaxDepthError{
+			MaxDepth: p.maxDepth,
+			Path:     p.path.String(),
+		}
+	}
+
+	return nil
+}
+
+func (p *msgpackParser) parseArray(n int) (interface{}, error) {
+	err := p.enter()
+	if err != nil {
+		return nil, err
+	}
+	elements := make([]interface{}, n)
+	for i := range elements {
+		p.path.pushIndex(i)
+		elements[i], err = p.parse()
+		if err != nil {
+			return nil, err
+		}
+		p.path.pop()
+	}
+	p.depth--
+
+	return elements, nil
+}
+
+func (p *msgpackParser) parseMap(n int) (interface{}, error) {
+	err := p.enter()
+	if err != nil {
+		return nil, err
+	}
+	x := &msgpackMap{
+		keys:   make([]interface{}, n),
+		values: make([]interface{}, n),
+	}
+	for i := 0; i < n; i++ {
+		x.keys[i], err = p.parse()
+		if err != nil {
+			return nil, err
+		}
+		p.path.pushKey(fmt.Sprint(x.keys[i]))
+		x.values[i], err = p.parse()
+		if err != nil {
+			return nil, err
+		}
+		p.path.pop()
+	}
+	p.depth--
+
+	return x, nil
+}
+
+// describeMsgpack names the kind of the decoded value x, for error messages.
+func describeMsgpack(x interface{}) string {
+	switch x := x.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "bool"
+	case int64, uint64, float64:
+		return fmt.Sprint("number ", x)
+	case string:
+		return "string"
+	case []byte:
+		return "binary"
+	case msgpackExt:
+		return fmt.Sprintf("extension type %d", x.code)
+	case []interface{}:
+		return "array"
+	}
+
+	return "map"
+}
+
+// msgpackKey returns the map key x as a string, when it's a string or an
+// integer.
+func msgpackKey(x interface{}) (string, bool) {
+	switch x := x.(type) {
+	case string:
+		return x, true
+	case int64:
+		return strconv.FormatInt(x, 10), true
+	case uint64:
+		return strconv.FormatUint(x, 10), true
+	}
+
+	return "", false
+}
+
+// isMsgpackStructural reports whether v is decoded by walking the MessagePack
+// value, rather than by decoding its JSON equivalent.
+func (m *CustomMarshaller) isMsgpackStructural(v reflect.Value) bool {
+	if _, ok := m.decoders[v.Type()]; ok {
+		return false
+	}
+	_, ok := unmarshaler(v)
+
+	return !ok
+}
+
+func (m *CustomMarshaller) unmarshalMsgpack(d *decodeState, x interface{}, v reflect.Value) error {
+	if ext, ok := m.extensions[v.Type()]; ok && x != nil {
+		e, ok := x.(msgpackExt)
+		if !ok || e.code != ext.code {
+			return fmt.Errorf("cannot unmarshal %s into %s", describeMsgpack(x), v.Type())
+		}
+		return ext.decode(e.data, v)
+	}
+	if x == nil || !m.isMsgpackStructural(v) {
+		return m.unmarshalMsgpackJSON(d, x, v)
+	}
+
+	switch k := v.Kind(); {
+	case k == reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		err := m.unmarshalMsgpack(d, x, v.Elem())
+		if err != nil {
+			return d.wrap(v.Type().Elem(), err)
+		}
+	case k == reflect.Interface && v.NumMethod() == 0:
+		// Decode into the existing value when it's a non-nil pointer, like
+		// Unmarshal.
+		if !v.IsNil() && v.Elem().Kind() == reflect.Ptr && !v.Elem().IsNil() {
+			err := m.unmarshalMsgpack(d, x, v.Elem())
+			if err != nil {
+				return d.wrap(v.Elem().Type(), err)
+			}
+			return nil
+		}
+		generic, err := m.msgpackGeneric(x)
+		if err != nil {
+			return err
+		}
+		if generic == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(generic))
+		}
+	case k == reflect.Struct:
+		return m.unmarshalMsgpackStruct(d, x, v)
+	case k == reflect.Slice && isByteSlice(v.Type()):
+		b, ok := x.([]byte)
+		if !ok {
+			return m.unmarshalMsgpackJSON(d, x, v)
+		}
+		v.SetBytes(append([]byte{}, b...))
+	case k == reflect.Slice || k == reflect.Array:
+		elements, ok := x.([]interface{})
+		if !ok {
+			return m.unmarshalMsgpackJSON(d, x, v)
+		}
+		err := d.enter()
+		if err != nil {
+			return err
+		}
+		if k == reflect.Slice {
+			v.Set(reflect.MakeSlice(v.Type(), len(elements), len(elements)))
+		}
+		// Like Unmarshal, extra elements are dropped and missing elements are
+		// zeroed.
+		for i := 0; i < v.Len(); i++ {
+			if i >= len(elements) {
+				v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+				continue
+			}
+			d.path.pushIndex(i)
+			err = m.unmarshalMsgpack(d, elements[i], v.Index(i))
+			if err != nil {
+				return d.wrap(v.Type().Elem(), err)
+			}
+			d.path.pop()
+		}
+		d.leave()
+	case k == reflect.Map:
+		object, ok := x.(*msgpackMap)
+		if !ok {
+			return m.unmarshalMsgpackJSON(d, x, v)
+		}
+		if !canParseMapKey(v.Type().Key()) {
+			return &UnsupportedTypeError{Type: v.Type()}
+		}
+		err := d.enter()
+		if err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(object.keys)))
+		}
+		for i, key := range object.keys {
+			name, ok := msgpackKey(key)
+			if !ok {
+				return fmt.Errorf("cannot unmarshal %s map key into %s", describeMsgpack(key), v.Type().Key())
+			}
+			element := reflect.New(v.Type().Elem()).Elem()
+			d.path.pushKey(name)
+			err = m.unmarshalMsgpack(d, object.values[i], element)
+			if err != nil {
+				return d.wrap(v.Type().Elem(), err)
+			}
+			d.path.pop()
+			mapKey, err := parseMapKey(name, v.Type().Key())
+			if err != nil {
+				return err
+			}
+			v.SetMapIndex(mapKey, element)
+		}
+		d.leave()
+	case k == reflect.Float32 || k == reflect.Float64:
+		// Floats are set directly, since JSON can't hold infinities and NaN.
+		f, ok := x.(float64)
+		if !ok || v.OverflowFloat(f) {
+			return m.unmarshalMsgpackJSON(d, x, v)
+		}
+		v.SetFloat(f)
+	default:
+		return m.unmarshalMsgpackJSON(d, x, v)
+	}
+
+	return nil
+}
+
+// unmarshalMsgpackStruct decodes a map keyed by target tag names, or an array
+// of fields in declaration order, into the struct v.
+func (m *CustomMarshaller) unmarshalMsgpackStruct(d *decodeState, x interface{}, v reflect.Value) error {
+	plan, err := m.structPlan(v.Type())
+	if err != nil {
+		return err
+	}
+
+	var fields []*fieldPlan
+	var values []interface{}
+	switch x := x.(type) {
+	case *msgpackMap:
+		for i, key := range x.keys {
+			name, ok := key.(string)
+			if !ok {
+				continue
+			}
+			field, ok := plan.matchField(name)
+			if !ok {
+				continue
+			}
+			fields = append(fields, field)
+			values = append(values, x.values[i])
+		}
+	case []interface{}:
+		if len(x) > len(plan.fields) {
+			return fmt.Errorf("cannot unmarshal array of %d elements into %s with %d fields", len(x), v.Type(), len(plan.fields))
+		}
+		for i := range x {
+			fields = append(fields, &plan.fields[i])
+		}
+		values = x
+	default:
+		return m.unmarshalMsgpackJSON(d, x, v)
+	}
+
+	err = d.enter()
+	if err != nil {
+		return err
+	}
+	for i, field := range fields {
+		fieldValue, err := fieldByIndexAlloc(v, field.Index)
+		if err != nil {
+			return err
+		}
+		if !fieldValue.CanSet() {
+			continue
+		}
+		d.path.pushField(field.TagValue)
+		if _, ok := values[i].([]byte); ok || (!field.Quoted && field.Bytes == defaultBytes) {
+			err = m.unmarshalMsgpack(d, values[i], fieldValue)
+		} else {
+			err = m.unmarshalMsgpackJSON(d, values[i], fieldValue, field)
+		}
+		if err != nil {
+			return d.wrap(fieldValue.Type(), err)
+		}
+		d.path.pop()
+	}
+	d.leave()
+
+	return nil
+}
+
+// unmarshalMsgpackJSON decodes x into v through its JSON equivalent, which
+// honours marshaler methods, registered decoders and the options of field,
+// when given.
+func (m *CustomMarshaller) unmarshalMsgpackJSON(d *decodeState, x interface{}, v reflect.Value, field ...*fieldPlan) error {
+	var b bytes.Buffer
+	err := appendMsgpackJSON(&b, x)
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal %s into %s", describeMsgpack(x), v.Type())
+	}
+	if len(field) > 0 {
+		return m.unmarshalField(d, b.Bytes(), v, field[0])
+	}
+
+	return m.unmarshal(d, b.Bytes(), v)
+}
+
+// errMsgpackJSON reports values without a JSON equivalent.
+var errMsgpackJSON = fmt.Errorf("no JSON equivalent")
+
+// appendMsgpackJSON writes the JSON equivalent of x, where binary becomes a
+// base64 string, as byte slices expect.
+func appendMsgpackJSON(b *bytes.Buffer, x interface{}) error {
+	switch x := x.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(x))
+	case int64:
+		b.WriteString(strconv.FormatInt(x, 10))
+	case uint64:
+		b.WriteString(strconv.FormatUint(x, 10))
+	case float64:
+		if math.IsInf(x, 0) || math.IsNaN(x) {
+			return errMsgpackJSON
+		}
+		b.WriteString(strconv.FormatFloat(x, 'g', -1, 64))
+	case string:
+		s, _ := json.Marshal(x)
+		b.Write(s)
+	case []byte:
+		b.WriteByte('"')
+		b.WriteString(base64.StdEncoding.EncodeToString(x))
+		b.WriteByte('"')
+	case []interface{}:
+		b.WriteByte('[')
+		for i, e := range x {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			err := appendMsgpackJSON(b, e)
+			if err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	case *msgpackMap:
+		b.WriteByte('{')
+		for i, key := range x.keys {
+			name, ok := msgpackKey(key)
+			if !ok {
+				return errMsgpackJSON
+			}
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			s, _ := json.Marshal(name)
+			b.Write(s)
+			b.WriteByte(':')
+			err := appendMsgpackJSON(b, x.values[i])
+			if err != nil {
+				return err
+			}
+		}
+		b.WriteByte('}')
+	default:
+		return errMsgpackJSON
+	}
+
+	return nil
+}
+
+// msgpackGeneric converts x into the generic representation Unmarshal uses
+// for empty interfaces, with float64 numbers, map[string]interface{} maps and
+// []interface{} arrays. Binary stays a byte slice, and extension types are
+// decoded into the types they're registered for.
+func (m *CustomMarshaller) msgpackGeneric(x interface{}) (interface{}, error) {
+	switch x := x.(type) {
+	case int64:
+		return float64(x), nil
+	case uint64:
+		return float64(x), nil
+	case []interface{}:
+		elements := make([]interface{}, len(x))
+		for i, e := range x {
+			var err error
+			elements[i], err = m.msgpackGeneric(e)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return elements, nil
+	case *msgpackMap:
+		object := make(map[string]interface{}, len(x.keys))
+		for i, key := range x.keys {
+			name, ok := msgpackKey(key)
+			if !ok {
+				return nil, fmt.Errorf("cannot unmarshal %s map key into string", describeMsgpack(key))
+			}
+			value, err := m.msgpackGeneric(x.values[i])
+			if err != nil {
+				return nil, err
+			}
+			object[name] = value
+		}
+		return object, nil
+	case msgpackExt:
+		t, ok := m.extensionType(x.code)
+		if !ok {
+			return nil, fmt.Errorf("cannot unmarshal unregistered %s", describeMsgpack(x))
+		}
+		v := reflect.New(t).Elem()
+		err := m.extensions[t].decode(x.data, v)
+		if err != nil {
+			return nil, err
+		}
+		return v.Interface(), nil
+	}
+
+	return x, nil
+}
@@ -0,0 +1,98 @@
+package structTags
+
+import (
+	"strings"
+	"unicode"
+)
+
+// UntaggedFieldPolicy decides how struct fields without a name in the target
+// tag are handled. That includes fields without the target tag, and fields
+// which only carry options, e.g. `custom:",omitempty"`. Fields with the
+// chardata or cdata option don't need a name, so SkipUntagged and
+// FailOnUntagged key them by their Go field name instead.
+type UntaggedFieldPolicy int
+
+const (
+	// UseFieldName keys untagged fields by their Go field name, as
+	// encoding/json does.
+	UseFieldName UntaggedFieldPolicy = iota
+	// SkipUntagged leaves untagged fields out entirely.
+	SkipUntagged
+	// UseNamingStrategy keys untagged fields by their Go field name, converted
+	// using the marshaller's FieldNaming strategy.
+	UseNamingStrategy
+	// FailOnUntagged makes marshalling and unmarshalling fail with an error
+	// naming the untagged field.
+	FailOnUntagged
+)
+
+// NamingStrategy converts Go field names into keys, for untagged fields.
+type NamingStrategy int
+
+const (
+	// SnakeCase converts e.g. "HTTPServerID" into "http_server_id".
+	SnakeCase NamingStrategy = iota
+	// CamelCase converts e.g. "HTTPServerID" into "httpServerId".
+	CamelCase
+	// KebabCase converts e.g. "HTTPServerID" into "http-server-id".
+	KebabCase
+	// ScreamingSnakeCase converts e.g. "HTTPServerID" into "HTTP_SERVER_ID".
+	ScreamingSnakeCase
+)
+
+// Apply converts the Go field name into a key using the naming strategy.
+func (n NamingStrategy) Apply(name string) string {
+	words := splitWords(name)
+	switch n {
+	case CamelCase:
+		for i := range words {
+			runes := []rune(strings.ToLower(words[i]))
+			if i > 0 {
+				runes[0] = unicode.ToUpper(runes[0])
+			}
+			words[i] = string(runes)
+		}
+		return strings.Join(words, "")
+	case KebabCase:
+		return strings.ToLower(strings.Join(words, "-"))
+	case ScreamingSnakeCase:
+		return strings.ToUpper(strings.Join(words, "_"))
+	}
+
+	return strings.ToLower(strings.Join(words, "_"))
+}
+
+// splitWords splits a Go identifier into its words. Acronyms are kept whole,
+// digits stick to the preceding word and underscores only separate words,
+// e.g. "HTTPServer_ID2" is split into "HTTP", "Server" and "ID2".
+func splitWords(name string) []string {
+	var words []string
+	runes := []rune(name)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '_' {
+			if start < i {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+			continue
+		}
+		if i == start || !unicode.IsUpper(runes[i]) {
+			continue
+		}
+		// An upper case letter starts a new word after a lower case letter or
+		// digit, or when it's the last letter of an acronym followed by a lower
+		// case letter.
+		previous := runes[i-1]
+		if unicode.IsLower(previous) || unicode.IsDigit(previous) ||
+			(unicode.IsUpper(previous) && i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+
+	return words
+}
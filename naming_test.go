@@ -0,0 +1,32 @@
+package structTags
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNamingStrategy_Apply(t *testing.T) {
+	testCases := []struct {
+		Input             string
+		ExpectedSnake     string
+		ExpectedCamel     string
+		ExpectedKebab     string
+		ExpectedScreaming string
+	}{
+		{"Field", "field", "field", "field", "FIELD"},
+		{"StringVar", "string_var", "stringVar", "string-var", "STRING_VAR"},
+		{"Int8Var", "int8_var", "int8Var", "int8-var", "INT8_VAR"},
+		{"HTTPServerID", "http_server_id", "httpServerId", "http-server-id", "HTTP_SERVER_ID"},
+		{"Already_Snake", "already_snake", "alreadySnake", "already-snake", "ALREADY_SNAKE"},
+		{"ÜberName", "über_name", "überName", "über-name", "ÜBER_NAME"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Input, func(t *testing.T) {
+			assert.Equal(t, testCase.ExpectedSnake, SnakeCase.Apply(testCase.Input))
+			assert.Equal(t, testCase.ExpectedCamel, CamelCase.Apply(testCase.Input))
+			assert.Equal(t, testCase.ExpectedKebab, KebabCase.Apply(testCase.Input))
+			assert.Equal(t, testCase.ExpectedScreaming, ScreamingSnakeCase.Apply(testCase.Input))
+		})
+	}
+}
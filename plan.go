@@ -0,0 +1,191 @@
+package structTags
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// encoderFunc writes v as JSON. Struct plans pick one for each field ahead of
+// time, so that the kind of the field doesn't have to be switched on again.
+type encoderFunc func(m *CustomMarshaller, w *encodeState, v reflect.Value) error
+
+// fieldPlan is a struct field along with everything needed to marshal it.
+type fieldPlan struct {
+	fieldMetadata
+	// key is the pre-escaped object key, including the colon.
+	key    []byte
+	encode encoderFunc
+}
+
+// unexportedField is an unexported field skipped by structFields, kept so that
+// it can be reported to OnUnexportedField.
+type unexportedField struct {
+	Owner reflect.Type
+	Field reflect.StructField
+}
+
+// structPlan is the cached encoding plan of a struct type.
+type structPlan struct {
+	fields []fieldPlan
+	// byName indexes fields by their tag values, for unmarshalling.
+	byName     map[string]int
+	unexported []unexportedField
+	// generatedMarshaler and generatedUnmarshaler report whether the struct
+	// has generated methods matching the plan.
+	generatedMarshaler   bool
+	generatedUnmarshaler bool
+}
+
+// planKey identifies a struct type along with the marshaller options which
+// affect its plan.
+type planKey struct {
+	Type                  reflect.Type
+	TargetTag             string
+	IgnoreTagWithValue    string
+	FallbackTags          string
+	UntaggedFields        UntaggedFieldPolicy
+	FieldNaming           NamingStrategy
+	SkipFuncAndChanFields bool
+	EscapeHTML            bool
+	ASCIIOnly             bool
+	InvalidUTF8           InvalidUTF8Policy
+}
+
+// structPlans caches a *structPlan for each planKey, across marshallers.
+var structPlans sync.Map
+
+// structPlan returns the plan of the struct type t, building and caching it on
+// first use. Unexported fields are reported to OnUnexportedField on each call.
+func (m *CustomMarshaller) structPlan(t reflect.Type) (*structPlan, error) {
+	key := planKey{
+		Type:                  t,
+		TargetTag:             m.TargetTag,
+		IgnoreTagWithValue:    m.IgnoreTagWithValue,
+		FallbackTags:          fallbackTagsKey(m.FallbackTags),
+		UntaggedFields:        m.UntaggedFields,
+		FieldNaming:           m.FieldNaming,
+		SkipFuncAndChanFields: m.SkipFuncAndChanFields,
+		EscapeHTML:            m.EscapeHTML,
+		ASCIIOnly:             m.ASCIIOnly,
+		InvalidUTF8:           m.InvalidUTF8,
+	}
+	cached, ok := structPlans.Load(key)
+	if !ok {
+		plan, err := m.buildStructPlan(t)
+		if err != nil {
+			return nil, err
+		}
+		cached, _ = structPlans.LoadOrStore(key, plan)
+	}
+	plan := cached.(*structPlan)
+
+	if m.OnUnexportedField != nil {
+		for _, f := range plan.unexported {
+			err := m.OnUnexportedField(f.Owner, f.Field)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// fallbackTagsKey joins tags for a planKey, prefixing each with its length so
+// that e.g. "a,b" and "a", "b" don't share a key.
+func fallbackTagsKey(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, tag := range tags {
+		b.WriteString(strconv.Itoa(len(tag)))
+		b.WriteByte(':')
+		b.WriteString(tag)
+	}
+
+	return b.String()
+}
+
+func (m *CustomMarshaller) buildStructPlan(t reflect.Type) (*structPlan, error) {
+	fields, unexported, err := m.structFields(t)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &structPlan{
+		fields:     make([]fieldPlan, len(fields)),
+		byName:     make(map[string]int, len(fields)),
+		unexported: unexported,
+	}
+	for i, f := range fields {
+		key, err := m.appendString(nil, f.TagValue)
+		if err != nil {
+			return nil, err
+		}
+		plan.fields[i] = fieldPlan{
+			fieldMetadata: f,
+			key:           append(key, ':'),
+			encode:        fieldEncoder(f),
+		}
+		plan.byName[f.TagValue] = i
+	}
+	if reflect.PtrTo(t).Implements(generatedMarshalerType) {
+		g := reflect.New(t).Interface().(GeneratedMarshaler)
+		plan.generatedMarshaler = m.matchesSchema(plan, g.CustomTagSchema())
+	}
+	if reflect.PtrTo(t).Implements(generatedUnmarshalerType) {
+		g := reflect.New(t).Interface().(GeneratedUnmarshaler)
+		plan.generatedUnmarshaler = m.matchesSchema(plan, g.CustomTagSchema())
+	}
+
+	return plan, nil
+}
+
+// fieldEncoder picks the encoder of the field f, according to its options and
+// type.
+func fieldEncoder(f fieldMetadata) encoderFunc {
+	if f.Quoted {
+		return func(m *CustomMarshaller, w *encodeState, v reflect.Value) error {
+			return m.marshalQuoted(w, v)
+		}
+	}
+	if f.Bytes != defaultBytes {
+		encoding := f.Bytes
+		return func(m *CustomMarshaller, w *encodeState, v reflect.Value) error {
+			// Nil slices are still null, as they are without the option.
+			if v.Kind() == reflect.Slice && v.IsNil() && !m.NilSliceAsEmpty {
+				return w.format.Scalar(reflect.Value{})
+			}
+			return m.marshalBytes(w, v, encoding)
+		}
+	}
+
+	return typeEncoder(f.Type)
+}
+
+// typeEncoder picks the encoder of values of type t. Scalars which don't
+// marshal themselves are reported to the format directly, unless the
+// marshaller has registered encoders or extension types which might apply to
+// them.
+func typeEncoder(t reflect.Type) encoderFunc {
+	if !isScalarKind(t.Kind()) || hasMarshalerMethods(t) {
+		return encodeValue
+	}
+
+	return encodeScalar
+}
+
+func encodeValue(m *CustomMarshaller, w *encodeState, v reflect.Value) error {
+	return m.marshal(w, v)
+}
+
+func encodeScalar(m *CustomMarshaller, w *encodeState, v reflect.Value) error {
+	if len(m.encoders) > 0 || len(m.extensions) > 0 {
+		return m.marshal(w, v)
+	}
+
+	return w.format.Scalar(v)
+}
@@ -0,0 +1,136 @@
+package structTags
+
+import (
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCustomMarshaller_StructPlan(t *testing.T) {
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	plan, err := m.structPlan(reflect.TypeOf(escapeStruct{}))
+	assert.NoError(t, err)
+	assert.Equal(t, `"quote\"var":`, string(plan.fields[0].key))
+	assert.Equal(t, `"map_var":`, string(plan.fields[1].key))
+
+	again, err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).structPlan(reflect.TypeOf(escapeStruct{}))
+	assert.NoError(t, err)
+	assert.Same(t, plan, again)
+
+	// Options which change the plan get their own.
+	m.TargetTag = "json"
+	jsonPlan, err := m.structPlan(reflect.TypeOf(escapeStruct{}))
+	assert.NoError(t, err)
+	assert.NotSame(t, plan, jsonPlan)
+	assert.Equal(t, `"mapVar":`, string(jsonPlan.fields[1].key))
+
+	m.TargetTag = targetCustomTag
+	m.EscapeHTML = true
+	htmlPlan, err := m.structPlan(reflect.TypeOf(escapeStruct{}))
+	assert.NoError(t, err)
+	assert.NotSame(t, plan, htmlPlan)
+}
+
+type fallbackKeyStruct struct {
+	Field string `b:"b_field"`
+}
+
+func TestCustomMarshaller_StructPlanFallbackTags(t *testing.T) {
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	m.FallbackTags = []string{"a", "b"}
+	b, err := m.Marshal(fallbackKeyStruct{Field: "x"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"b_field":"x"}`+"\n", string(b))
+
+	// A single tag containing a comma is a different configuration.
+	m.FallbackTags = []string{"a,b"}
+	b, err = m.Marshal(fallbackKeyStruct{Field: "x"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"Field":"x"}`+"\n", string(b))
+}
+
+func TestCustomMarshaller_StructPlanConcurrency(t *testing.T) {
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	input := parentStruct{ChildStructVar: childStruct{GrandChildStructVar: grandChildStruct{StringVar: "str"}}}
+	expected, err := m.Marshal(input)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				b, err := m.Marshal(input)
+				assert.NoError(t, err)
+				assert.Equal(t, string(expected), string(b))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkCustomMarshaller_Marshal(b *testing.B) {
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	input := scalarStruct{StringVar: "str", IntVar: 1, Float64Var: 1.5, BoolVar: true}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := m.Marshal(input)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCustomMarshaller_AppendMarshal(b *testing.B) {
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	input := scalarStruct{StringVar: "str", IntVar: 1, Float64Var: 1.5, BoolVar: true}
+	dst := make([]byte, 0, 1024)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var err error
+		dst, err = m.AppendMarshal(dst[:0], input)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type benchmarkItem struct {
+	Name   string            `custom:"name"`
+	Count  int               `custom:"count"`
+	Tags   []string          `custom:"tags"`
+	Labels map[string]string `custom:"labels"`
+	Child  *grandChildStruct `custom:"child"`
+}
+
+func newBenchmarkItems() []benchmarkItem {
+	items := make([]benchmarkItem, 50)
+	for i := range items {
+		items[i] = benchmarkItem{
+			Name:   "item",
+			Count:  i,
+			Tags:   []string{"a", "b"},
+			Labels: map[string]string{"k": "v"},
+			Child:  &grandChildStruct{StringVar: "str"},
+		}
+	}
+
+	return items
+}
+
+func BenchmarkCustomMarshaller_MarshalNested(b *testing.B) {
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	input := newBenchmarkItems()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := m.Marshal(input)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
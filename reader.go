@@ -0,0 +1,124 @@
+package structTags
+
+import (
+	"bytes"
+	"encoding/json"
+	"unicode/utf8"
+)
+
+// jsonReader reads the values of a JSON document in order, in a single pass.
+// The document must already be known to be valid, so that values only have to
+// be found, not checked.
+type jsonReader struct {
+	data []byte
+	off  int
+}
+
+// peek returns the first byte of the next token, skipping whitespace, or zero
+// at the end of the document.
+func (r *jsonReader) peek() byte {
+	for r.off < len(r.data) {
+		switch r.data[r.off] {
+		case ' ', '\t', '\n', '\r':
+			r.off++
+		default:
+			return r.data[r.off]
+		}
+	}
+
+	return 0
+}
+
+// value returns the next value whole, moving past it.
+func (r *jsonReader) value() []byte {
+	r.peek()
+	start := r.off
+	depth := 0
+	for r.off < len(r.data) {
+		switch r.data[r.off] {
+		case '"':
+			r.skipString()
+			if depth == 0 {
+				return r.data[start:r.off]
+			}
+			continue
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth == 0 {
+				return r.data[start:r.off]
+			}
+			depth--
+			if depth == 0 {
+				r.off++
+				return r.data[start:r.off]
+			}
+		case ',', ':', ' ', '\t', '\n', '\r':
+			if depth == 0 {
+				return r.data[start:r.off]
+			}
+		}
+		r.off++
+	}
+
+	return r.data[start:r.off]
+}
+
+// skipString moves past the string starting at the current offset.
+func (r *jsonReader) skipString() {
+	r.off++
+	for r.off < len(r.data) {
+		switch r.data[r.off] {
+		case '\\':
+			r.off += 2
+			continue
+		case '"':
+			r.off++
+			return
+		}
+		r.off++
+	}
+}
+
+// begin moves past the opening brace or bracket of an object or array.
+func (r *jsonReader) begin() {
+	r.peek()
+	r.off++
+}
+
+// more reports whether the object or array being read has another member,
+// moving past the comma before it, or past end once there are none left.
+func (r *jsonReader) more(end byte) bool {
+	c := r.peek()
+	if c == ',' {
+		r.off++
+		c = r.peek()
+	}
+	if c == end {
+		r.off++
+		return false
+	}
+
+	return c != 0
+}
+
+// key reads the key of the next object member, along with the colon after it.
+func (r *jsonReader) key() string {
+	data := r.value()
+	r.peek()
+	r.off++
+
+	if len(data) < 2 {
+		return ""
+	}
+	s := data[1 : len(data)-1]
+	if bytes.IndexByte(s, '\\') < 0 && utf8.Valid(s) {
+		return string(s)
+	}
+	// Leave escapes and invalid UTF-8 to encoding/json, so that keys are
+	// decoded exactly as it decodes them.
+	var key string
+	_ = json.Unmarshal(data, &key)
+
+	return key
+}
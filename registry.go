@@ -0,0 +1,87 @@
+package structTags
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// EncoderFunc writes the JSON encoding of v to w. The output must be a single
+// valid JSON value, and is compacted before it is written to the document.
+type EncoderFunc func(w io.Writer, v reflect.Value) error
+
+// DecoderFunc decodes the JSON-encoded data into v, which is settable. It's
+// never called for null, which leaves v unchanged or resets pointers as usual.
+type DecoderFunc func(data []byte, v reflect.Value) error
+
+// RegisterEncoder makes values of type t marshal using fn, wherever they are
+// found. Registered encoders take priority over marshaler interfaces and the
+// default encoding of each kind.
+//
+// Registering is not safe to do concurrently with marshalling.
+func (m *CustomMarshaller) RegisterEncoder(t reflect.Type, fn func(w io.Writer, v reflect.Value) error) {
+	if m.encoders == nil {
+		m.encoders = map[reflect.Type]EncoderFunc{}
+	}
+	m.encoders[t] = fn
+}
+
+// RegisterDecoder makes values of type t unmarshal using fn, wherever they are
+// found. Registered decoders take priority over unmarshaler interfaces and
+// the default decoding of each kind.
+//
+// Registering is not safe to do concurrently with unmarshalling.
+func (m *CustomMarshaller) RegisterDecoder(t reflect.Type, fn func(data []byte, v reflect.Value) error) {
+	if m.decoders == nil {
+		m.decoders = map[reflect.Type]DecoderFunc{}
+	}
+	m.decoders[t] = fn
+}
+
+// hasEncoderBehind reports whether the non-nil pointer v leads to a value with
+// a registered encoder, which then takes priority over any marshaler methods
+// of the pointer type.
+func (m *CustomMarshaller) hasEncoderBehind(v reflect.Value) bool {
+	if len(m.encoders) == 0 {
+		return false
+	}
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+		if _, ok := m.encoders[v.Type()]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// marshalRegistered reports v using its registered encoder. Like the output
+// of marshalers, the encoder's output is validated and compacted.
+func marshalRegistered(w *encodeState, v reflect.Value, fn EncoderFunc) error {
+	b := getBuffer()
+	defer putBuffer(b)
+	err := fn(b, v)
+	if err != nil {
+		return fmt.Errorf("failed to call registered encoder for type %s: %w", v.Type(), err)
+	}
+
+	compact := getBuffer()
+	defer putBuffer(compact)
+	err = json.Compact(compact, b.Bytes())
+	if err != nil {
+		return fmt.Errorf("registered encoder for type %s produced invalid JSON: %w", v.Type(), err)
+	}
+
+	return w.writeJSON(compact.Bytes())
+}
+
+// unmarshalRegistered decodes data into v using its registered decoder.
+func unmarshalRegistered(data []byte, v reflect.Value, fn DecoderFunc) error {
+	err := fn(data, v)
+	if err != nil {
+		return fmt.Errorf("failed to call registered decoder for type %s: %w", v.Type(), err)
+	}
+
+	return nil
+}
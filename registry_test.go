@@ -0,0 +1,175 @@
+package structTags
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math/big"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+type registryStruct struct {
+	AddrVar    netip.Addr            `json:"addrVar" custom:"addr_var"`
+	AddrPtrVar *netip.Addr           `json:"addrPtrVar" custom:"addr_ptr_var"`
+	AddrsVar   []netip.Addr          `json:"addrsVar" custom:"addrs_var"`
+	AddrMapVar map[string]netip.Addr `json:"addrMapVar" custom:"addr_map_var"`
+	BigVar     *big.Int              `json:"bigVar" custom:"big_var"`
+}
+
+// newRegistryMarshaller registers netip.Addr as an array of bytes and *big.Int
+// as a string, instead of their own marshaler methods.
+func newRegistryMarshaller() *CustomMarshaller {
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	m.RegisterEncoder(reflect.TypeOf(netip.Addr{}), func(w io.Writer, v reflect.Value) error {
+		addr := v.Interface().(netip.Addr)
+		if !addr.Is4() {
+			return errors.New("not an IPv4 address")
+		}
+		b := addr.As4()
+		_, err := fmt.Fprintf(w, "[%d,%d,%d,%d]", b[0], b[1], b[2], b[3])
+		return err
+	})
+	m.RegisterDecoder(reflect.TypeOf(netip.Addr{}), func(data []byte, v reflect.Value) error {
+		var b [4]byte
+		err := json.Unmarshal(data, &b)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(netip.AddrFrom4(b)))
+		return nil
+	})
+	m.RegisterEncoder(reflect.TypeOf(&big.Int{}), func(w io.Writer, v reflect.Value) error {
+		_, err := fmt.Fprintf(w, "%q", v.Interface().(*big.Int).String())
+		return err
+	})
+	m.RegisterDecoder(reflect.TypeOf(&big.Int{}), func(data []byte, v reflect.Value) error {
+		var s string
+		err := json.Unmarshal(data, &s)
+		if err != nil {
+			return err
+		}
+		n, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		v.Set(reflect.ValueOf(n))
+		return nil
+	})
+
+	return m
+}
+
+func TestCustomMarshaller_RegisterEncoder(t *testing.T) {
+	addr := netip.MustParseAddr("10.0.0.2")
+	big, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+
+	testCases := []struct {
+		Name           string
+		Input          any
+		ExpectedError  error
+		ExpectedOutput string
+	}{
+		{
+			Name: "registry struct",
+			Input: registryStruct{
+				AddrVar:    netip.MustParseAddr("10.0.0.1"),
+				AddrPtrVar: &addr,
+				AddrsVar:   []netip.Addr{netip.MustParseAddr("10.0.0.3")},
+				AddrMapVar: map[string]netip.Addr{"a": netip.MustParseAddr("10.0.0.4")},
+				BigVar:     big,
+			},
+			ExpectedError: nil,
+			ExpectedOutput: `{"addr_var":[10,0,0,1],"addr_ptr_var":[10,0,0,2],"addrs_var":[[10,0,0,3]],"addr_map_var":{"a":[10,0,0,4]},"big_var":"123456789012345678901234567890"}
+`,
+		},
+		{
+			Name:           "encoder error",
+			Input:          []netip.Addr{netip.MustParseAddr("::1")},
+			ExpectedError:  errors.New("failed to marshal [0]: failed to call registered encoder for type netip.Addr: not an IPv4 address"),
+			ExpectedOutput: "",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			b, err := newRegistryMarshaller().Marshal(testCase.Input)
+			assertError(t, testCase.ExpectedError, err)
+			assert.Equal(t, testCase.ExpectedOutput, string(b))
+		})
+	}
+}
+
+type registryID int
+
+func TestCustomMarshaller_RegisterEncoder_Output(t *testing.T) {
+	testCases := []struct {
+		Name           string
+		Output         string
+		ExpectedError  error
+		ExpectedOutput string
+	}{
+		{
+			Name:           "compacted",
+			Output:         " [ 1,\n 2 ] ",
+			ExpectedOutput: `{"id":[1,2]}` + "\n",
+		},
+		{
+			Name:          "invalid",
+			Output:        "{bad",
+			ExpectedError: errors.New("failed to marshal id: registered encoder for type structTags.registryID produced invalid JSON: invalid character 'b' looking for beginning of object key string"),
+		},
+		{
+			Name:          "empty",
+			Output:        "",
+			ExpectedError: errors.New("failed to marshal id: registered encoder for type structTags.registryID produced invalid JSON: unexpected end of JSON input"),
+		},
+		{
+			Name:          "several values",
+			Output:        "1 2",
+			ExpectedError: errors.New("failed to marshal id: registered encoder for type structTags.registryID produced invalid JSON: invalid character '2' after top-level value"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+			m.RegisterEncoder(reflect.TypeOf(registryID(0)), func(w io.Writer, v reflect.Value) error {
+				_, err := io.WriteString(w, testCase.Output)
+				return err
+			})
+			b, err := m.Marshal(struct {
+				ID registryID `custom:"id"`
+			}{})
+			assertError(t, testCase.ExpectedError, err)
+			assert.Equal(t, testCase.ExpectedOutput, string(b))
+
+			var marshalErr *MarshalError
+			if testCase.ExpectedError != nil {
+				assert.True(t, errors.As(err, &marshalErr))
+			}
+		})
+	}
+}
+
+func TestCustomMarshaller_RegisterDecoder(t *testing.T) {
+	var output registryStruct
+	err := newRegistryMarshaller().Unmarshal([]byte(`{"addr_var":[10,0,0,1],"addr_ptr_var":[10,0,0,2],"addrs_var":[[10,0,0,3]],"addr_map_var":{"a":[10,0,0,4]},"big_var":"123456789012345678901234567890"}`), &output)
+	assert.NoError(t, err)
+
+	addr := netip.MustParseAddr("10.0.0.2")
+	big, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	assert.Equal(t, registryStruct{
+		AddrVar:    netip.MustParseAddr("10.0.0.1"),
+		AddrPtrVar: &addr,
+		AddrsVar:   []netip.Addr{netip.MustParseAddr("10.0.0.3")},
+		AddrMapVar: map[string]netip.Addr{"a": netip.MustParseAddr("10.0.0.4")},
+		BigVar:     big,
+	}, output)
+
+	err = newRegistryMarshaller().Unmarshal([]byte(`{"big_var":"1.5"}`), &output)
+	assert.EqualError(t, err, `failed to unmarshal big_var: failed to call registered decoder for type *big.Int: invalid integer "1.5"`)
+}
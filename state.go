@@ -0,0 +1,261 @@
+package structTags
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxDepth is the maximum nesting depth of objects and arrays used
+// when a CustomMarshaller's MaxDepth is zero.
+const DefaultMaxDepth = 10000
+
+// pathSegment is a single step from a value into one of its fields, elements
+// or map entries.
+type pathSegment struct {
+	Name  string
+	Index int
+	Kind  reflect.Kind
+}
+
+// path tracks where the walk currently is, using target tag names for fields.
+type path []pathSegment
+
+func (p *path) pushField(name string) {
+	*p = append(*p, pathSegment{Name: name, Kind: reflect.Struct})
+}
+
+func (p *path) pushIndex(i int) {
+	*p = append(*p, pathSegment{Index: i, Kind: reflect.Slice})
+}
+
+func (p *path) pushKey(key string) {
+	*p = append(*p, pathSegment{Name: key, Kind: reflect.Map})
+}
+
+func (p *path) pop() {
+	*p = (*p)[:len(*p)-1]
+}
+
+// String renders the path like "parent.children[3].name", or "(root)" for the
+// top-level value.
+func (p path) String() string {
+	if len(p) == 0 {
+		return "(root)"
+	}
+
+	var b strings.Builder
+	for i, segment := range p {
+		switch segment.Kind {
+		case reflect.Struct:
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(segment.Name)
+		case reflect.Slice:
+			b.WriteByte('[')
+			b.WriteString(strconv.Itoa(segment.Index))
+			b.WriteByte(']')
+		case reflect.Map:
+			b.WriteByte('[')
+			b.WriteString(strconv.Quote(segment.Name))
+			b.WriteByte(']')
+		}
+	}
+
+	return b.String()
+}
+
+// visit identifies a pointer, map or slice which is being walked.
+type visit struct {
+	Ptr  uintptr
+	Type reflect.Type
+	Len  int
+}
+
+// maxPooledBuffer is the capacity above which buffers aren't returned to the
+// pool, so that a single huge value doesn't stay in memory.
+const maxPooledBuffer = 64 << 10
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxPooledBuffer {
+		return
+	}
+	b.Reset()
+	bufferPool.Put(b)
+}
+
+// encodeState carries the bookkeeping of a single marshalling walk, which
+// reports the values it finds to a Format.
+type encodeState struct {
+	format Format
+	// json is the format of walks producing JSON, which is kept here so that
+	// it isn't allocated separately.
+	json     jsonFormat
+	maxDepth int
+	depth    int
+	path     path
+	// visited maps the pointers, maps and slices being walked to the length of
+	// the path where the walk first reached them. That path is still a prefix
+	// of the current one, so it's only rendered once a cycle is found.
+	visited map[visit]int
+	// generated holds the encoders handed to generated methods, which are
+	// reused for each struct at the same depth of generated calls.
+	generated      []*GeneratedEncoder
+	generatedDepth int
+}
+
+func (m *CustomMarshaller) newEncodeState(f Format) *encodeState {
+	maxDepth := m.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxDepth
+	}
+
+	return &encodeState{
+		format:   f,
+		maxDepth: maxDepth,
+	}
+}
+
+// newJSONState returns the state of a walk writing JSON to b. Output is
+// buffered, and only reaches the destination once the walk succeeds.
+func (m *CustomMarshaller) newJSONState(b *bytes.Buffer) *encodeState {
+	s := m.newEncodeState(nil)
+	s.json = jsonFormat{m: m, b: b}
+	s.format = &s.json
+
+	return s
+}
+
+// asJSON returns the format of the walk, when it writes JSON.
+func (s *encodeState) asJSON() (*jsonFormat, bool) {
+	f, ok := s.format.(*jsonFormat)
+	return f, ok
+}
+
+// fieldKey reports the key of a struct field, writing its pre-escaped form
+// when the walk writes JSON.
+func (s *encodeState) fieldKey(field *fieldPlan) error {
+	if f, ok := s.asJSON(); ok {
+		f.rawKey(field.key)
+		return nil
+	}
+
+	return s.format.Key(field.TagValue)
+}
+
+// writeJSON reports the JSON encoding data, produced by a marshaler or a
+// registered encoder, as the next value.
+func (s *encodeState) writeJSON(data []byte) error {
+	if f, ok := s.asJSON(); ok {
+		f.raw(data)
+		return nil
+	}
+
+	return replayJSON(s.format, data)
+}
+
+// enter records that the walk descends into v. Objects and arrays count
+// towards the maximum depth, and pointers, maps and slices which are already
+// being walked make up a cycle.
+func (s *encodeState) enter(v reflect.Value) error {
+	k := v.Kind()
+	if k == reflect.Struct || k == reflect.Map || k == reflect.Slice || k == reflect.Array {
+		s.depth++
+		if s.maxDepth > 0 && s.depth > s.maxDepth {
+			return &MaxDepthError{
+				MaxDepth: s.maxDepth,
+				Path:     s.path.String(),
+			}
+		}
+	}
+	if (k == reflect.Ptr || k == reflect.Map || k == reflect.Slice) && !v.IsNil() {
+		key := visit{
+			Ptr:  v.Pointer(),
+			Type: v.Type(),
+		}
+		if k == reflect.Slice {
+			key.Len = v.Len()
+		}
+		if s.visited == nil {
+			s.visited = map[visit]int{}
+		}
+		start, ok := s.visited[key]
+		if ok {
+			return &CycleError{
+				Type:  v.Type(),
+				Start: s.path[:start].String(),
+				Path:  s.path.String(),
+			}
+		}
+		s.visited[key] = len(s.path)
+	}
+
+	return nil
+}
+
+// leave undoes enter, once the walk is done with v.
+func (s *encodeState) leave(v reflect.Value) {
+	k := v.Kind()
+	if k == reflect.Struct || k == reflect.Map || k == reflect.Slice || k == reflect.Array {
+		s.depth--
+	}
+	if (k == reflect.Ptr || k == reflect.Map || k == reflect.Slice) && !v.IsNil() {
+		key := visit{
+			Ptr:  v.Pointer(),
+			Type: v.Type(),
+		}
+		if k == reflect.Slice {
+			key.Len = v.Len()
+		}
+		delete(s.visited, key)
+	}
+}
+
+// decodeState carries the bookkeeping of a single unmarshalling walk.
+type decodeState struct {
+	maxDepth int
+	depth    int
+	path     path
+}
+
+func (m *CustomMarshaller) newDecodeState() *decodeState {
+	maxDepth := m.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxDepth
+	}
+
+	return &decodeState{
+		maxDepth: maxDepth,
+	}
+}
+
+// enter records that the walk descends into a JSON object or array.
+func (d *decodeState) enter() error {
+	d.depth++
+	if d.maxDepth > 0 && d.depth > d.maxDepth {
+		return &MaxDepthError{
+			MaxDepth: d.maxDepth,
+			Path:     d.path.String(),
+		}
+	}
+
+	return nil
+}
+
+// leave undoes enter.
+func (d *decodeState) leave() {
+	d.depth--
+}
@@ -0,0 +1,122 @@
+package structTags
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type cycleStruct struct {
+	NameVar  string         `json:"nameVar" custom:"name_var"`
+	NextVar  *cycleStruct   `json:"nextVar" custom:"next_var,omitempty"`
+	Children []*cycleStruct `json:"children" custom:"children,omitempty"`
+}
+
+func TestCustomMarshaller_MarshalCycle(t *testing.T) {
+	self := &cycleStruct{NameVar: "self"}
+	self.NextVar = self
+
+	first := &cycleStruct{NameVar: "first"}
+	second := &cycleStruct{NameVar: "second", NextVar: first}
+	first.Children = []*cycleStruct{{NameVar: "child"}, second}
+
+	nested := &cycleStruct{NameVar: "nested"}
+	nested.Children = []*cycleStruct{{NameVar: "loop"}}
+	nested.Children[0].NextVar = nested.Children[0]
+
+	selfMap := map[string]any{}
+	selfMap["self"] = selfMap
+
+	shared := &cycleStruct{NameVar: "shared"}
+
+	testCases := []struct {
+		Name           string
+		Input          any
+		ExpectedError  error
+		ExpectedOutput string
+	}{
+		{
+			Name:  "self",
+			Input: self,
+			ExpectedError: &CycleError{
+				Type:  reflect.TypeOf(self),
+				Start: "(root)",
+				Path:  "next_var",
+			},
+		},
+		{
+			Name:  "indirect",
+			Input: first,
+			ExpectedError: &CycleError{
+				Type:  reflect.TypeOf(first),
+				Start: "(root)",
+				Path:  "children[1].next_var",
+			},
+		},
+		{
+			Name:  "below the root",
+			Input: nested,
+			ExpectedError: &CycleError{
+				Type:  reflect.TypeOf(nested),
+				Start: "children[0]",
+				Path:  "children[0].next_var",
+			},
+		},
+		{
+			Name:          "map",
+			Input:         selfMap,
+			ExpectedError: errors.New(`encountered a cycle via map[string]interface {}: ["self"] refers back to (root)`),
+		},
+		{
+			Name:           "shared without cycle",
+			Input:          []*cycleStruct{shared, shared},
+			ExpectedOutput: `[{"name_var":"shared"},{"name_var":"shared"}]` + "\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			b, err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).Marshal(testCase.Input)
+			if testCase.ExpectedError == nil {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.ExpectedOutput, string(b))
+				return
+			}
+			var cycleErr *CycleError
+			assert.True(t, errors.As(err, &cycleErr))
+			if expected, ok := testCase.ExpectedError.(*CycleError); ok {
+				assert.Equal(t, expected, cycleErr)
+			} else {
+				assert.EqualError(t, err, testCase.ExpectedError.Error())
+			}
+		})
+	}
+}
+
+func TestCustomMarshaller_MaxDepth(t *testing.T) {
+	input := parentStruct{ChildStructVar: childStruct{GrandChildStructVar: grandChildStruct{StringVar: "str"}}}
+
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	m.MaxDepth = 2
+	_, err := m.Marshal(input)
+	assert.Equal(t, &MaxDepthError{MaxDepth: 2, Path: "child_struct_var.grand_child_struct_var"}, err)
+
+	m.MaxDepth = 3
+	b, err := m.Marshal(input)
+	assert.NoError(t, err)
+
+	m.MaxDepth = 2
+	var output parentStruct
+	err = m.Unmarshal(b, &output)
+	assert.Equal(t, &MaxDepthError{MaxDepth: 2, Path: "child_struct_var.grand_child_struct_var"}, err)
+
+	var generic any
+	err = m.Unmarshal([]byte(`{"a":[[1]]}`), &generic)
+	assert.Equal(t, &MaxDepthError{MaxDepth: 2, Path: `["a"][0]`}, err)
+
+	m.MaxDepth = -1
+	deep := strings.Repeat("[", 200) + strings.Repeat("]", 200)
+	assert.NoError(t, m.Unmarshal([]byte(deep), &generic))
+}
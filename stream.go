@@ -0,0 +1,139 @@
+package structTags
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Decoder reads and decodes custom-tagged JSON values from an input stream.
+type Decoder struct {
+	m   *CustomMarshaller
+	dec *json.Decoder
+}
+
+// NewDecoder returns a new decoder that reads from r, matching object keys to
+// struct fields using the pre-configured target tag and ignored tag values.
+//
+// The decoder introduces its own buffering and may read data from r beyond
+// the JSON values requested.
+func (m *CustomMarshaller) NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		m:   m,
+		dec: json.NewDecoder(r),
+	}
+}
+
+// Decode reads the next JSON-encoded value from its input and stores it in the
+// value pointed to by obj.
+func (d *Decoder) Decode(obj interface{}) error {
+	v, err := unmarshalTarget(obj)
+	if err != nil {
+		return err
+	}
+
+	var raw json.RawMessage
+	err = d.dec.Decode(&raw)
+	if err != nil {
+		return err
+	}
+
+	s := d.m.newDecodeState()
+	err = d.m.unmarshal(s, raw, v)
+	if err != nil {
+		return s.wrap(v.Type(), err)
+	}
+
+	return nil
+}
+
+// More reports whether there is another element in the current array or
+// object being parsed.
+func (d *Decoder) More() bool {
+	return d.dec.More()
+}
+
+// Token returns the next JSON token in the input stream. At the end of the
+// input stream, Token returns nil, io.EOF. See json.Decoder.Token for the
+// types of the returned tokens.
+func (d *Decoder) Token() (json.Token, error) {
+	return d.dec.Token()
+}
+
+// Buffered returns a reader of the data remaining in the Decoder's buffer. The
+// reader is valid until the next call to Decode.
+func (d *Decoder) Buffered() io.Reader {
+	return d.dec.Buffered()
+}
+
+// Encoder writes custom-tagged JSON values to an output stream.
+type Encoder struct {
+	m               *CustomMarshaller
+	w               io.Writer
+	prefix          string
+	indent          string
+	escapeHTML      bool
+	trailingNewline bool
+}
+
+// NewEncoder returns a new encoder that writes to w, using the pre-configured
+// target tag and ignored tag values. Like Marshal, each encoded value is
+// followed by a newline character, until SetTrailingNewline(false) is called.
+func (m *CustomMarshaller) NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		m:               m,
+		w:               w,
+		escapeHTML:      m.EscapeHTML,
+		trailingNewline: true,
+	}
+}
+
+// Encode writes the JSON encoding of obj to the stream. Nothing is written if
+// obj fails to marshal.
+func (e *Encoder) Encode(obj interface{}) error {
+	m := *e.m
+	m.EscapeHTML = e.escapeHTML
+
+	b := getBuffer()
+	defer putBuffer(b)
+	err := m.marshalBuffer(b, obj, false)
+	if err != nil {
+		return err
+	}
+
+	if e.prefix != "" || e.indent != "" {
+		indented := getBuffer()
+		defer putBuffer(indented)
+		err = json.Indent(indented, b.Bytes(), e.prefix, e.indent)
+		if err != nil {
+			return err
+		}
+		b = indented
+	}
+	if e.trailingNewline {
+		b.WriteByte('\n')
+	}
+
+	_, err = e.w.Write(b.Bytes())
+	return err
+}
+
+// SetIndent instructs the encoder to format each subsequent encoded value as
+// if indented by MarshalIndent. Calling SetIndent("", "") disables indentation.
+func (e *Encoder) SetIndent(prefix, indent string) {
+	e.prefix = prefix
+	e.indent = indent
+}
+
+// SetEscapeHTML specifies whether problematic HTML characters should be
+// escaped inside JSON quoted strings, overriding the marshaller's EscapeHTML
+// option for this encoder. Unlike encoding/json, escaping is disabled by
+// default, so that the output of an Encoder matches the output of Marshal.
+func (e *Encoder) SetEscapeHTML(on bool) {
+	e.escapeHTML = on
+}
+
+// SetTrailingNewline specifies whether a newline character is written after
+// each encoded value. It's enabled by default.
+func (e *Encoder) SetTrailingNewline(on bool) {
+	e.trailingNewline = on
+}
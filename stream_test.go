@@ -0,0 +1,181 @@
+package structTags
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecoder_Decode(t *testing.T) {
+	testCases := []struct {
+		Name           string
+		Input          string
+		ExpectedError  error
+		ExpectedOutput []grandChildStruct
+	}{
+		{
+			Name:           "empty",
+			Input:          "",
+			ExpectedError:  nil,
+			ExpectedOutput: nil,
+		},
+		{
+			Name:           "newline-delimited",
+			Input:          "{\"string_var\":\"a\"}\n{\"string_var\":\"b\"}\n",
+			ExpectedError:  nil,
+			ExpectedOutput: []grandChildStruct{{StringVar: "a"}, {StringVar: "b"}},
+		},
+		{
+			Name:           "concatenated",
+			Input:          `{"string_var":"a"}{"string_var":"b"} {}`,
+			ExpectedError:  nil,
+			ExpectedOutput: []grandChildStruct{{StringVar: "a"}, {StringVar: "b"}, {}},
+		},
+		{
+			Name:           "type mismatch",
+			Input:          `{"string_var":"a"} {"string_var":true}`,
+			ExpectedError:  errors.New("failed to unmarshal string_var: cannot unmarshal bool into string"),
+			ExpectedOutput: []grandChildStruct{{StringVar: "a"}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			dec := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).NewDecoder(strings.NewReader(testCase.Input))
+			var output []grandChildStruct
+			var err error
+			for {
+				var s grandChildStruct
+				err = dec.Decode(&s)
+				if err != nil {
+					break
+				}
+				output = append(output, s)
+			}
+			if testCase.ExpectedError == nil {
+				assert.Equal(t, io.EOF, err)
+			} else {
+				assertError(t, testCase.ExpectedError, err)
+			}
+			assert.Equal(t, testCase.ExpectedOutput, output)
+		})
+	}
+}
+
+func TestDecoder_Token(t *testing.T) {
+	dec := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).NewDecoder(strings.NewReader(`[{"string_var":"a"},{"string_var":"b"}] trailing`))
+
+	token, err := dec.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, json.Delim('['), token)
+
+	var output []grandChildStruct
+	for dec.More() {
+		var s grandChildStruct
+		assert.NoError(t, dec.Decode(&s))
+		output = append(output, s)
+	}
+	assert.Equal(t, []grandChildStruct{{StringVar: "a"}, {StringVar: "b"}}, output)
+
+	token, err = dec.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, json.Delim(']'), token)
+
+	rest, err := io.ReadAll(dec.Buffered())
+	assert.NoError(t, err)
+	assert.Equal(t, " trailing", string(rest))
+}
+
+func TestEncoder_Encode(t *testing.T) {
+	testCases := []struct {
+		Name            string
+		Input           []any
+		Prefix          string
+		Indent          string
+		EscapeHTML      bool
+		TrailingNewline bool
+		ExpectedError   error
+		ExpectedOutput  string
+	}{
+		{
+			Name:            "compact",
+			Input:           []any{grandChildStruct{StringVar: "a"}, grandChildStruct{StringVar: "b"}},
+			TrailingNewline: true,
+			ExpectedOutput: `{"string_var":"a"}
+{"string_var":"b"}
+`,
+		},
+		{
+			Name:            "no trailing newline",
+			Input:           []any{grandChildStruct{StringVar: "a"}, grandChildStruct{StringVar: "b"}},
+			TrailingNewline: false,
+			ExpectedOutput:  `{"string_var":"a"}{"string_var":"b"}`,
+		},
+		{
+			Name:            "indent",
+			Input:           []any{parentStruct{}, []int{1, 2}},
+			Prefix:          ">",
+			Indent:          "  ",
+			TrailingNewline: true,
+			ExpectedOutput: `{
+>  "child_struct_var": {
+>    "grand_child_struct_var": {
+>      "string_var": ""
+>    }
+>  }
+>}
+[
+>  1,
+>  2
+>]
+`,
+		},
+		{
+			Name:            "escape html",
+			Input:           []any{grandChildStruct{StringVar: "<a&b>"}},
+			EscapeHTML:      true,
+			TrailingNewline: true,
+			ExpectedOutput: `{"string_var":"\u003ca\u0026b\u003e"}
+`,
+		},
+		{
+			Name:            "html",
+			Input:           []any{grandChildStruct{StringVar: "<a&b>"}},
+			EscapeHTML:      false,
+			TrailingNewline: true,
+			ExpectedOutput: `{"string_var":"<a&b>"}
+`,
+		},
+		{
+			Name:            "error",
+			Input:           []any{grandChildStruct{StringVar: "a"}, map[float64]int{1: 1}},
+			TrailingNewline: true,
+			ExpectedError:   errors.New("failed to marshal (root): unsupported type: map[float64]int"),
+			ExpectedOutput: `{"string_var":"a"}
+`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			b := bytes.NewBuffer([]byte{})
+			enc := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).NewEncoder(b)
+			enc.SetIndent(testCase.Prefix, testCase.Indent)
+			enc.SetEscapeHTML(testCase.EscapeHTML)
+			enc.SetTrailingNewline(testCase.TrailingNewline)
+			var err error
+			for _, input := range testCase.Input {
+				err = enc.Encode(input)
+				if err != nil {
+					break
+				}
+			}
+			assertError(t, testCase.ExpectedError, err)
+			assert.Equal(t, testCase.ExpectedOutput, b.String())
+		})
+	}
+}
@@ -12,28 +12,84 @@ package structTags
 
 import (
 	"bytes"
-	"errors"
-	"fmt"
+	"encoding/json"
 	"io"
 	"reflect"
-	"sort"
-	"strconv"
 )
 
-const (
-	ErrNilObject = "object was nil"
-)
-
-// fieldMetadata helps maintain the order of a temporary list of reflect.Value field objects.
-type fieldMetadata struct {
-	TagValue string
-	Value    reflect.Value
-}
-
 // CustomMarshaller allows for marshalling non-JSON and third-party struct tags.
 type CustomMarshaller struct {
 	TargetTag          string
 	IgnoreTagWithValue string
+
+	// FallbackTags are consulted in order for fields without the target tag.
+	// The first tag present on a field provides both its name and whether it
+	// is ignored, e.g. []string{"json"} falls back to encoding/json names.
+	FallbackTags []string
+
+	// UntaggedFields decides how fields without a name in the target tag are
+	// keyed. By default, the Go field name is used.
+	UntaggedFields UntaggedFieldPolicy
+	// FieldNaming converts the Go field names of untagged fields, when
+	// UntaggedFields is UseNamingStrategy.
+	FieldNaming NamingStrategy
+
+	// EscapeHTML escapes <, >, &, U+2028 and U+2029 inside strings, so that
+	// the output can be embedded in HTML.
+	EscapeHTML bool
+	// ASCIIOnly escapes every non-ASCII character inside strings.
+	ASCIIOnly bool
+	// InvalidUTF8 decides how strings containing invalid UTF-8 are handled.
+	// By default, invalid bytes are replaced with U+FFFD.
+	InvalidUTF8 InvalidUTF8Policy
+
+	// NilSliceAsEmpty marshals nil slices as empty arrays instead of null.
+	NilSliceAsEmpty bool
+	// NilMapAsEmpty marshals nil maps as empty objects instead of null.
+	NilMapAsEmpty bool
+
+	// ComplexEncoding decides how complex numbers are marshalled. By default,
+	// they fail to marshal.
+	ComplexEncoding ComplexEncoding
+	// SkipFuncAndChanFields skips struct fields of func and chan kinds, which
+	// otherwise fail to marshal and unmarshal.
+	SkipFuncAndChanFields bool
+
+	// IgnoreGeneratedMethods walks structs through reflection even when they
+	// have methods generated by cmd/structtags-gen.
+	IgnoreGeneratedMethods bool
+
+	// OnUnexportedField, when set, is called with the unexported fields which
+	// are skipped, so that they can be logged or rejected. It's called for
+	// every struct value marshalled or unmarshalled, rather than once per
+	// type, so a slice of structs reports the same fields for each element.
+	OnUnexportedField UnexportedFieldHook
+
+	// XMLRootName names the root element of MarshalXMLDocument. Empty means
+	// DefaultXMLRootName.
+	XMLRootName string
+
+	// CSVComma separates the fields of MarshalCSV and UnmarshalCSV. Zero means
+	// a comma, and '\t' reads and writes TSV.
+	CSVComma rune
+
+	// MsgpackStructsAsArrays makes MarshalMsgpack encode structs as arrays of
+	// their fields, in declaration order, rather than as maps keyed by target
+	// tag names.
+	MsgpackStructsAsArrays bool
+
+	// MaxDepth limits how deeply objects and arrays may be nested, when both
+	// marshalling and unmarshalling. Zero means DefaultMaxDepth, and a
+	// negative value removes the limit.
+	MaxDepth int
+
+	encoders map[reflect.Type]EncoderFunc
+	decoders map[reflect.Type]DecoderFunc
+	// extensions holds the MessagePack extension types registered for Go
+	// types, and extensionTypes the Go types registered for each extension
+	// type, in the order they were registered.
+	extensions     map[reflect.Type]msgpackExtension
+	extensionTypes map[int8][]reflect.Type
 }
 
 // NewCustomMarshaller creates a new custom-tag marshalling instance.
@@ -44,172 +100,239 @@ func NewCustomMarshaller(targetTag, ignoreTag string) *CustomMarshaller {
 	}
 }
 
-func (m *CustomMarshaller) marshal(w io.Writer, obj interface{}, top bool) error {
-	if obj == nil {
-		return errors.New(ErrNilObject)
+// marshal walks v, reporting it to the format of w. It takes a reflect.Value
+// rather than an interface{}, so that walking into fields, elements and
+// pointers doesn't allocate.
+func (m *CustomMarshaller) marshal(w *encodeState, v reflect.Value) error {
+	var t reflect.Type
+	k := v.Kind()
+	if v.IsValid() {
+		t = v.Type()
 	}
 
-	v := reflect.ValueOf(obj)
-	t := reflect.TypeOf(obj)
-	if val, ok := obj.(reflect.Value); ok {
-		v = val
-		t = val.Type()
-	}
-	k := t.Kind()
-
-	if k == reflect.Struct {
-		// Remove ignored fields and ensure that the order of the fields won't change.
-		fields := map[int]fieldMetadata{}
-		count := 0
-		for i := 0; i < v.NumField(); i++ {
-			tagValue := v.Type().Field(i).Tag.Get(m.TargetTag)
-			if tagValue == m.IgnoreTagWithValue {
-				continue
-			}
-			fields[count] = fieldMetadata{
-				TagValue: tagValue,
-				Value:    v.Field(i),
-			}
-			count++
+	if k == reflect.Invalid || ((k == reflect.Ptr || k == reflect.Interface) && v.IsNil()) {
+		err := w.format.Scalar(reflect.Value{})
+		if err != nil {
+			return err
 		}
-
-		_, err := w.Write([]byte("{"))
+	} else if f, ext, ok := m.extensionFormat(w, t); ok {
+		data, err := ext.encode(v)
 		if err != nil {
 			return err
 		}
-		for x := 0; x < len(fields); x++ {
-			_, err = w.Write([]byte(fmt.Sprintf("%q:", fields[x].TagValue)))
-			if err != nil {
-				return err
-			}
-			err = m.marshal(w, fields[x].Value, false)
-			if err != nil {
-				return fmt.Errorf("failed to marshal struct field: %s", err.Error())
-			}
-			if x+1 < len(fields) {
-				_, err = w.Write([]byte(","))
-				if err != nil {
-					return err
-				}
-			}
+		err = f.Extension(ext.code, data)
+		if err != nil {
+			return err
 		}
-		_, err = w.Write([]byte("}"))
+	} else if fn, ok := m.encoders[t]; ok {
+		err := marshalRegistered(w, v, fn)
 		if err != nil {
 			return err
 		}
-	} else if k == reflect.Slice {
-		_, err := w.Write([]byte("["))
+	} else if isMarshaler(v) && !m.hasEncoderBehind(v) {
+		err := m.marshalMarshaler(w, v)
 		if err != nil {
 			return err
 		}
-		for i := 0; i < v.Len(); i++ {
-			if err != nil {
-				return err
-			}
-			err = m.marshal(w, v.Index(i), false)
-			if err != nil {
-				return fmt.Errorf("failed to marshal slice element: %s", err.Error())
-			}
-			if i+1 < v.Len() {
-				_, err = w.Write([]byte(","))
-				if err != nil {
-					return err
-				}
-			}
+	} else if (k == reflect.Slice && v.IsNil() && !m.NilSliceAsEmpty) || (k == reflect.Map && v.IsNil() && !m.NilMapAsEmpty) {
+		err := w.format.Scalar(reflect.Value{})
+		if err != nil {
+			return err
 		}
-		_, err = w.Write([]byte("]"))
+	} else if k == reflect.Struct {
+		plan, err := m.structPlan(t)
 		if err != nil {
 			return err
 		}
-	} else if k == reflect.Map {
-		_, err := w.Write([]byte("{"))
+		err = w.enter(v)
 		if err != nil {
 			return err
 		}
-		var keys []string
-		for _, key := range v.MapKeys() {
-			keys = append(keys, key.String())
+
+		if g, f, ok := m.generatedMarshaler(w, v, plan); ok {
+			err = m.marshalGenerated(w, f, v, plan, g)
+		} else if f, ok := w.format.(StructFormat); ok {
+			err = f.Struct(&StructWriter{m: m, w: w, v: v, plan: plan})
+		} else {
+			err = m.marshalFields(w, v, plan)
 		}
-		sort.Strings(keys)
-		for i := 0; i < len(keys); i++ {
-			_, err = w.Write([]byte(fmt.Sprintf("%q:", keys[i])))
-			if err != nil {
-				return err
-			}
-			err = m.marshal(w, v.MapIndex(reflect.ValueOf(keys[i])), false)
-			if err != nil {
-				return fmt.Errorf("failed to marshal map field: %s", err.Error())
-			}
-			if i+1 < v.Len() {
-				_, err = w.Write([]byte(","))
-				if err != nil {
-					return err
-				}
-			}
+		if err != nil {
+			return err
 		}
-		_, err = w.Write([]byte("}"))
+		w.leave(v)
+	} else if f, ok := w.format.(BinaryFormat); ok && k == reflect.Slice && isByteSlice(t) {
+		err := f.Binary(bytesOf(v))
 		if err != nil {
 			return err
 		}
-	} else if k == reflect.Ptr {
-		err := m.marshal(w, v.Elem(), false)
+	} else if k == reflect.Slice && isByteSlice(t) {
+		err := m.marshalBytes(w, v, base64Bytes)
 		if err != nil {
-			return fmt.Errorf("failed to marshal ptr: %s", err.Error())
+			return err
 		}
-	} else if k == reflect.Interface {
-		err := m.marshal(w, v.Interface(), false)
+	} else if k == reflect.Slice || k == reflect.Array {
+		err := m.marshalArray(w, v)
 		if err != nil {
-			return fmt.Errorf("failed to marshal interface: %s", err.Error())
+			return err
 		}
-	} else if k == reflect.Int || k == reflect.Int8 || k == reflect.Int16 || k == reflect.Int32 || k == reflect.Int64 {
-		_, err := w.Write([]byte(strconv.FormatInt(v.Int(), 10)))
+	} else if k == reflect.Map {
+		err := w.enter(v)
 		if err != nil {
 			return err
 		}
-	} else if k == reflect.Uint || k == reflect.Uint8 || k == reflect.Uint16 || k == reflect.Uint32 || k == reflect.Uint64 || k == reflect.Uintptr {
-		_, err := w.Write([]byte(strconv.FormatUint(v.Uint(), 10)))
+		err = w.format.BeginObject()
 		if err != nil {
 			return err
 		}
-	} else if k == reflect.Float32 {
-		_, err := w.Write([]byte(strconv.FormatFloat(v.Float(), 'f', -1, 32)))
+		keys, err := resolveMapKeys(v)
 		if err != nil {
 			return err
 		}
-	} else if k == reflect.Float64 {
-		_, err := w.Write([]byte(strconv.FormatFloat(v.Float(), 'f', -1, 64)))
+		for i := 0; i < len(keys); i++ {
+			err = w.format.Key(keys[i].Name)
+			if err != nil {
+				return err
+			}
+			w.path.pushKey(keys[i].Name)
+			value := v.MapIndex(keys[i].Value)
+			err = m.marshal(w, value)
+			if err != nil {
+				return w.wrap(value.Type(), err)
+			}
+			w.path.pop()
+		}
+		err = w.format.EndObject()
 		if err != nil {
 			return err
 		}
-	} else if k == reflect.Complex64 || k == reflect.Complex128 {
-		_, err := w.Write([]byte(fmt.Sprint(v.Complex())))
+		w.leave(v)
+	} else if k == reflect.Ptr {
+		err := w.enter(v)
 		if err != nil {
 			return err
 		}
-	} else if k == reflect.String {
-		_, err := w.Write([]byte(fmt.Sprintf("%q", v.String())))
+		err = m.marshal(w, v.Elem())
+		if err != nil {
+			return w.wrap(v.Type().Elem(), err)
+		}
+		w.leave(v)
+	} else if k == reflect.Interface {
+		err := m.marshal(w, v.Elem())
+		if err != nil {
+			return w.wrap(v.Elem().Type(), err)
+		}
+	} else if k == reflect.Complex64 || k == reflect.Complex128 {
+		err := m.marshalComplex(w, v)
 		if err != nil {
 			return err
 		}
-	} else if k == reflect.Bool {
-		_, err := w.Write([]byte(fmt.Sprintf("%v", v.Bool())))
+	} else if isScalarKind(k) {
+		err := w.format.Scalar(v)
 		if err != nil {
 			return err
 		}
 	} else {
-		_, err := w.Write([]byte(v.String()))
+		return &UnsupportedTypeError{Type: t}
+	}
+
+	return nil
+}
+
+// marshalFields reports the struct v as an object, walking the fields of its
+// plan through reflection.
+func (m *CustomMarshaller) marshalFields(w *encodeState, v reflect.Value, plan *structPlan) error {
+	err := w.format.BeginObject()
+	if err != nil {
+		return err
+	}
+	for x := 0; x < len(plan.fields); x++ {
+		field := &plan.fields[x]
+		value, ok := fieldByIndex(v, field.Index)
+		if !ok {
+			continue
+		}
+		if field.OmitEmpty && isEmptyValue(value) {
+			continue
+		}
+		if field.OmitZero && isZeroValue(value) {
+			continue
+		}
+		err = w.fieldKey(field)
 		if err != nil {
 			return err
 		}
+		w.path.pushField(field.TagValue)
+		err = field.encode(m, w, value)
+		if err != nil {
+			return w.wrap(value.Type(), err)
+		}
+		w.path.pop()
+	}
+	err = w.format.EndObject()
+	if err != nil {
+		return err
 	}
 
-	// Perform top-level logic.
-	if top {
-		_, err := w.Write([]byte("\n"))
+	return nil
+}
+
+// marshalArray reports the slice or array v as an array.
+func (m *CustomMarshaller) marshalArray(w *encodeState, v reflect.Value) error {
+	err := w.enter(v)
+	if err != nil {
+		return err
+	}
+	err = w.format.BeginArray()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < v.Len(); i++ {
+		w.path.pushIndex(i)
+		err = m.marshal(w, v.Index(i))
 		if err != nil {
-			return err
+			return w.wrap(v.Type().Elem(), err)
 		}
-		return nil
+		w.path.pop()
+	}
+	err = w.format.EndArray()
+	if err != nil {
+		return err
+	}
+	w.leave(v)
+
+	return nil
+}
+
+// marshalQuoted reports v as a string containing its JSON encoding, for fields
+// with the string tag option. Nil pointers are still reported as null.
+func (m *CustomMarshaller) marshalQuoted(w *encodeState, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return w.format.Scalar(reflect.Value{})
+	}
+
+	b := getBuffer()
+	defer putBuffer(b)
+	quoted := *w
+	quoted.json = jsonFormat{m: m, b: b}
+	quoted.format = &quoted.json
+	err := m.marshal(&quoted, v)
+	if err != nil {
+		return err
+	}
+
+	return w.format.Scalar(reflect.ValueOf(b.String()))
+}
+
+// marshalBuffer marshals obj into b as JSON, attaching the root path to errors
+// which don't carry one yet. When top is true, a newline follows the value.
+func (m *CustomMarshaller) marshalBuffer(b *bytes.Buffer, obj interface{}, top bool) error {
+	s := m.newJSONState(b)
+	err := m.marshal(s, reflect.ValueOf(obj))
+	if err != nil {
+		return s.wrap(reflect.TypeOf(obj), err)
+	}
+	if top {
+		b.WriteByte('\n')
 	}
 
 	return nil
@@ -218,11 +341,59 @@ func (m *CustomMarshaller) marshal(w io.Writer, obj interface{}, top bool) error
 // Marshal takes the provided object and JSON-marshals it using the
 // pre-configured target tag and ignored tag values.
 func (m *CustomMarshaller) Marshal(obj interface{}) ([]byte, error) {
-	w := bytes.NewBuffer([]byte{})
-	err := m.marshal(w, obj, true)
+	b, err := m.AppendMarshal(nil, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// AppendMarshal is like Marshal, but appends the output to dst and returns the
+// extended slice. On error, dst is returned as is.
+func (m *CustomMarshaller) AppendMarshal(dst []byte, obj interface{}) ([]byte, error) {
+	b := getBuffer()
+	defer putBuffer(b)
+	err := m.marshalBuffer(b, obj, true)
+	if err != nil {
+		return dst, err
+	}
+
+	return append(dst, b.Bytes()...), nil
+}
+
+// MarshalTo is like Marshal, but writes the output to w. The output is
+// buffered, so that w receives a single Write call, and nothing at all when
+// obj fails to marshal.
+func (m *CustomMarshaller) MarshalTo(w io.Writer, obj interface{}) error {
+	b := getBuffer()
+	defer putBuffer(b)
+	err := m.marshalBuffer(b, obj, true)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b.Bytes())
+
+	return err
+}
+
+// MarshalIndent is like Marshal, but applies json.Indent to format the output.
+// Each JSON element begins on a new line beginning with prefix, followed by
+// one or more copies of indent according to the indentation nesting.
+func (m *CustomMarshaller) MarshalIndent(obj interface{}, prefix, indent string) ([]byte, error) {
+	w := getBuffer()
+	defer putBuffer(w)
+	err := m.marshalBuffer(w, obj, false)
+	if err != nil {
+		return nil, err
+	}
+
+	b := bytes.NewBuffer(make([]byte, 0, w.Len()))
+	err = json.Indent(b, w.Bytes(), prefix, indent)
 	if err != nil {
 		return nil, err
 	}
+	b.WriteByte('\n')
 
-	return w.Bytes(), nil
+	return b.Bytes(), nil
 }
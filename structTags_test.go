@@ -1,8 +1,11 @@
 package structTags
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"reflect"
 	"testing"
 )
 
@@ -19,6 +22,17 @@ var (
 	}
 )
 
+// assertError compares err with the expected error by message, since errors
+// wrap the underlying cause with the path of the offending value.
+func assertError(t *testing.T, expected, err error) {
+	t.Helper()
+	if expected == nil {
+		assert.NoError(t, err)
+		return
+	}
+	assert.EqualError(t, err, expected.Error())
+}
+
 type scalarStruct struct {
 	StringVar  string  `json:"stringVar" custom:"string_var"`
 	IntVar     int     `json:"intVar" custom:"int_var"`
@@ -85,6 +99,83 @@ type grandChildStruct struct {
 	StringVar string `json:"stringVar" custom:"string_var"`
 }
 
+// positiveNumber considers all non-positive numbers to be zero.
+type positiveNumber struct {
+	Value int `json:"value" custom:"value"`
+}
+
+func (n positiveNumber) IsZero() bool {
+	return n.Value <= 0
+}
+
+type optionStruct struct {
+	EmptyStringVar    string           `json:"emptyStringVar,omitempty" custom:"empty_string_var,omitempty"`
+	EmptySliceVar     []int            `json:"emptySliceVar,omitempty" custom:"empty_slice_var,omitempty"`
+	EmptyPtrVar       *string          `json:"emptyPtrVar,omitempty" custom:"empty_ptr_var,omitempty"`
+	ZeroStructVar     grandChildStruct `json:"zeroStructVar,omitzero" custom:"zero_struct_var,omitzero"`
+	ZeroerVar         positiveNumber   `json:"zeroerVar,omitzero" custom:"zeroer_var,omitzero"`
+	QuotedIntVar      int              `json:"quotedIntVar,string" custom:"quoted_int_var,string"`
+	QuotedBoolVar     bool             `json:"quotedBoolVar,string" custom:"quoted_bool_var,string"`
+	QuotedStringVar   string           `json:"quotedStringVar,string" custom:"quoted_string_var,string"`
+	QuotedFloatPtrVar *float64         `json:"quotedFloatPtrVar,string" custom:"quoted_float_ptr_var,string"`
+	DashVar           string           `json:"-," custom:"-,"`
+}
+
+type untaggedStruct struct {
+	TaggedVar    string `json:"taggedVar" custom:"tagged_var"`
+	UntaggedVar  string
+	HTTPServerID int `json:",omitempty" custom:",omitempty"`
+}
+
+type fallbackStruct struct {
+	APIVar     string `api:"api_var" json:"apiVar"`
+	JSONVar    string `json:"jsonVar,omitempty"`
+	IgnoredVar string `api:"-" json:"ignoredVar"`
+	ExposedVar string `api:"exposed_var" json:"-"`
+	YAMLVar    string `yaml:"yaml_var" json:"-"`
+	BareVar    string
+}
+
+type embeddedStruct struct {
+	IDVar    int    `json:"id_var" custom:"id_var"`
+	NameVar  string `json:"name_var" custom:"name_var"`
+	LabelVar string
+}
+
+type EmbeddedPtrStruct struct {
+	NameVar  string `json:"name_var" custom:"name_var"`
+	NoteVar  string `json:"note_var" custom:"note_var"`
+	OtherVar string `json:"LabelVar" custom:"LabelVar"`
+}
+
+type embeddingStruct struct {
+	embeddedStruct
+	*EmbeddedPtrStruct
+	IDVar       int              `json:"id_var" custom:"id_var"`
+	InlineVar   grandChildStruct `json:"inline_var" custom:"inline_var,inline"`
+	NamedVar    embeddedStruct   `json:"named_var" custom:"named_var"`
+	TaggedEmbed grandChildStruct `json:"tagged_embed" custom:"tagged_embed"`
+}
+
+type unexportedStruct struct {
+	embeddedStruct
+	ExportedVar   string      `json:"exportedVar" custom:"exported_var"`
+	taggedVar     string      `custom:"tagged_var"`
+	interfaceVar  interface{} `custom:"interface_var"`
+	childVar      childStruct
+	namedEmbedVar embeddedStruct `custom:"named_embed_var,inline"`
+}
+
+type nilStruct struct {
+	PtrVar       *string           `json:"ptrVar" custom:"ptr_var"`
+	StructPtrVar *grandChildStruct `json:"structPtrVar" custom:"struct_ptr_var"`
+	SliceVar     []string          `json:"sliceVar" custom:"slice_var"`
+	BytesVar     []byte            `json:"bytesVar" custom:"bytes_var"`
+	MapVar       map[string]int    `json:"mapVar" custom:"map_var"`
+	AnyVar       interface{}       `json:"anyVar" custom:"any_var"`
+	AnySliceVar  []interface{}     `json:"anySliceVar" custom:"any_slice_var"`
+}
+
 func TestNewCustomMarshaller(t *testing.T) {
 	testCases := []struct {
 		Name           string
@@ -93,16 +184,25 @@ func TestNewCustomMarshaller(t *testing.T) {
 		ExpectedOutput string
 	}{
 		{
-			Name:           "nil",
-			Input:          nil,
-			ExpectedError:  errors.New(ErrNilObject),
-			ExpectedOutput: "",
+			Name:          "nil",
+			Input:         nil,
+			ExpectedError: nil,
+			ExpectedOutput: `null
+`,
 		},
 		{
-			Name:           "nil interface",
-			Input:          new(interface{}),
-			ExpectedError:  errors.New("failed to marshal ptr: failed to marshal interface: object was nil"),
-			ExpectedOutput: "",
+			Name:          "nil interface",
+			Input:         new(interface{}),
+			ExpectedError: nil,
+			ExpectedOutput: `null
+`,
+		},
+		{
+			Name:          "nil struct ptr",
+			Input:         (*scalarStruct)(nil),
+			ExpectedError: nil,
+			ExpectedOutput: `null
+`,
 		},
 		{
 			Name:          "interface",
@@ -210,7 +310,7 @@ func TestNewCustomMarshaller(t *testing.T) {
 			Name:          "uint8 slice",
 			Input:         []uint8{13, 14},
 			ExpectedError: nil,
-			ExpectedOutput: `[13,14]
+			ExpectedOutput: `"DQ4="
 `,
 		},
 		{
@@ -268,7 +368,7 @@ func TestNewCustomMarshaller(t *testing.T) {
 				IgnoredSliceVar: []string{"ignored", "example"},
 			},
 			ExpectedError: nil,
-			ExpectedOutput: `{"string_slice_var":["str","ing"],"int_slice_var":[1,2],"int8_slice_var":[3,4],"int16_slice_var":[5,6],"int32_slice_var":[7,8],"int64_slice_var":[9,10],"uint_slice_var":[11,12],"uint8_slice_var":[13,14],"uint16_slice_var":[15,16],"uint32_slice_var":[17,18],"uint64_slice_var":[19,20],"float32_slice_var":[21.1,22.2],"float64_slice_var":[23.3,24.4],"bool_slice_var":[true,false]}
+			ExpectedOutput: `{"string_slice_var":["str","ing"],"int_slice_var":[1,2],"int8_slice_var":[3,4],"int16_slice_var":[5,6],"int32_slice_var":[7,8],"int64_slice_var":[9,10],"uint_slice_var":[11,12],"uint8_slice_var":"DQ4=","uint16_slice_var":[15,16],"uint32_slice_var":[17,18],"uint64_slice_var":[19,20],"float32_slice_var":[21.1,22.2],"float64_slice_var":[23.3,24.4],"bool_slice_var":[true,false]}
 `,
 		},
 		{
@@ -291,7 +391,7 @@ func TestNewCustomMarshaller(t *testing.T) {
 				IgnoredSliceVar: []string{"ignored", "example"},
 			},
 			ExpectedError: nil,
-			ExpectedOutput: `{"string_slice_var":["str","ing"],"int_slice_var":[1,2],"int8_slice_var":[3,4],"int16_slice_var":[5,6],"int32_slice_var":[7,8],"int64_slice_var":[9,10],"uint_slice_var":[11,12],"uint8_slice_var":[13,14],"uint16_slice_var":[15,16],"uint32_slice_var":[17,18],"uint64_slice_var":[19,20],"float32_slice_var":[21.1,22.2],"float64_slice_var":[23.3,24.4],"bool_slice_var":[false,true]}
+			ExpectedOutput: `{"string_slice_var":["str","ing"],"int_slice_var":[1,2],"int8_slice_var":[3,4],"int16_slice_var":[5,6],"int32_slice_var":[7,8],"int64_slice_var":[9,10],"uint_slice_var":[11,12],"uint8_slice_var":"DQ4=","uint16_slice_var":[15,16],"uint32_slice_var":[17,18],"uint64_slice_var":[19,20],"float32_slice_var":[21.1,22.2],"float64_slice_var":[23.3,24.4],"bool_slice_var":[false,true]}
 `,
 		},
 		{
@@ -442,6 +542,57 @@ func TestNewCustomMarshaller(t *testing.T) {
 			},
 			ExpectedError: nil,
 			ExpectedOutput: `{"child_struct_var":{"grand_child_struct_var":{"string_var":"str"}}}
+`,
+		},
+		{
+			Name: "empty option struct",
+			Input: optionStruct{
+				ZeroerVar: positiveNumber{Value: -1},
+			},
+			ExpectedError: nil,
+			ExpectedOutput: `{"quoted_int_var":"0","quoted_bool_var":"false","quoted_string_var":"\"\"","quoted_float_ptr_var":null,"-":""}
+`,
+		},
+		{
+			Name: "option struct",
+			Input: optionStruct{
+				EmptyStringVar:    "str",
+				EmptySliceVar:     []int{1},
+				EmptyPtrVar:       new(string),
+				ZeroStructVar:     grandChildStruct{StringVar: "str"},
+				ZeroerVar:         positiveNumber{Value: 1},
+				QuotedIntVar:      2,
+				QuotedBoolVar:     true,
+				QuotedStringVar:   "str",
+				QuotedFloatPtrVar: new(float64),
+				DashVar:           "dash",
+			},
+			ExpectedError: nil,
+			ExpectedOutput: `{"empty_string_var":"str","empty_slice_var":[1],"empty_ptr_var":"","zero_struct_var":{"string_var":"str"},"zeroer_var":{"value":1},"quoted_int_var":"2","quoted_bool_var":"true","quoted_string_var":"\"str\"","quoted_float_ptr_var":"0","-":"dash"}
+`,
+		},
+		{
+			Name: "embedding struct",
+			Input: embeddingStruct{
+				embeddedStruct:    embeddedStruct{IDVar: 1, NameVar: "a", LabelVar: "b"},
+				EmbeddedPtrStruct: &EmbeddedPtrStruct{NameVar: "c", NoteVar: "d", OtherVar: "e"},
+				IDVar:             2,
+				InlineVar:         grandChildStruct{StringVar: "f"},
+				NamedVar:          embeddedStruct{IDVar: 3},
+				TaggedEmbed:       grandChildStruct{StringVar: "g"},
+			},
+			ExpectedError: nil,
+			ExpectedOutput: `{"note_var":"d","LabelVar":"e","id_var":2,"string_var":"f","named_var":{"id_var":3,"name_var":"","LabelVar":""},"tagged_embed":{"string_var":"g"}}
+`,
+		},
+		{
+			Name: "embedding struct nil ptr",
+			Input: embeddingStruct{
+				embeddedStruct: embeddedStruct{IDVar: 1, NameVar: "a", LabelVar: "b"},
+				IDVar:          2,
+			},
+			ExpectedError: nil,
+			ExpectedOutput: `{"id_var":2,"string_var":"","named_var":{"id_var":0,"name_var":"","LabelVar":""},"tagged_embed":{"string_var":""}}
 `,
 		},
 	}
@@ -449,7 +600,296 @@ func TestNewCustomMarshaller(t *testing.T) {
 	for _, testCase := range testCases {
 		t.Run(testCase.Name, func(t *testing.T) {
 			b, err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).Marshal(testCase.Input)
-			assert.Equal(t, testCase.ExpectedError, err)
+			assertError(t, testCase.ExpectedError, err)
+			assert.Equal(t, testCase.ExpectedOutput, string(b))
+		})
+	}
+}
+
+func TestCustomMarshaller_MarshalIndent(t *testing.T) {
+	b, err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).MarshalIndent(parentStruct{
+		ChildStructVar: childStruct{
+			GrandChildStructVar: grandChildStruct{
+				StringVar: "str",
+			},
+		},
+	}, "", "\t")
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+	"child_struct_var": {
+		"grand_child_struct_var": {
+			"string_var": "str"
+		}
+	}
+}
+`, string(b))
+}
+
+// countingWriter records the writes it receives.
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestCustomMarshaller_AppendMarshal(t *testing.T) {
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	input := parentStruct{ChildStructVar: childStruct{GrandChildStructVar: grandChildStruct{StringVar: "str"}}}
+	expected, err := m.Marshal(input)
+	assert.NoError(t, err)
+
+	dst := make([]byte, 0, 256)
+	dst = append(dst, "prefix:"...)
+	b, err := m.AppendMarshal(dst, input)
+	assert.NoError(t, err)
+	assert.Equal(t, "prefix:"+string(expected), string(b))
+	assert.Same(t, &dst[:1][0], &b[0])
+
+	b, err = m.AppendMarshal(dst, map[float64]int{1: 1})
+	assert.EqualError(t, err, "failed to marshal (root): unsupported type: map[float64]int")
+	assert.Equal(t, "prefix:", string(b))
+}
+
+func TestCustomMarshaller_MarshalTo(t *testing.T) {
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	input := parentStruct{ChildStructVar: childStruct{GrandChildStructVar: grandChildStruct{StringVar: "str"}}}
+	expected, err := m.Marshal(input)
+	assert.NoError(t, err)
+
+	w := &countingWriter{}
+	assert.NoError(t, m.MarshalTo(w, input))
+	assert.Equal(t, string(expected), w.String())
+	assert.Equal(t, 1, w.writes)
+
+	w = &countingWriter{}
+	err = m.MarshalTo(w, []interface{}{1, func() {}})
+	assert.EqualError(t, err, "failed to marshal [1]: unsupported type: func()")
+	assert.Equal(t, 0, w.writes)
+}
+
+func TestCustomMarshaller_UntaggedFields(t *testing.T) {
+	testCases := []struct {
+		Name           string
+		UntaggedFields UntaggedFieldPolicy
+		FieldNaming    NamingStrategy
+		ExpectedError  error
+		ExpectedOutput string
+	}{
+		{
+			Name:           "field name",
+			UntaggedFields: UseFieldName,
+			ExpectedOutput: `{"tagged_var":"a","UntaggedVar":"b","HTTPServerID":1}
+`,
+		},
+		{
+			Name:           "skip",
+			UntaggedFields: SkipUntagged,
+			ExpectedOutput: `{"tagged_var":"a"}
+`,
+		},
+		{
+			Name:           "snake case",
+			UntaggedFields: UseNamingStrategy,
+			FieldNaming:    SnakeCase,
+			ExpectedOutput: `{"tagged_var":"a","untagged_var":"b","http_server_id":1}
+`,
+		},
+		{
+			Name:           "camel case",
+			UntaggedFields: UseNamingStrategy,
+			FieldNaming:    CamelCase,
+			ExpectedOutput: `{"tagged_var":"a","untaggedVar":"b","httpServerId":1}
+`,
+		},
+		{
+			Name:           "kebab case",
+			UntaggedFields: UseNamingStrategy,
+			FieldNaming:    KebabCase,
+			ExpectedOutput: `{"tagged_var":"a","untagged-var":"b","http-server-id":1}
+`,
+		},
+		{
+			Name:           "screaming snake case",
+			UntaggedFields: UseNamingStrategy,
+			FieldNaming:    ScreamingSnakeCase,
+			ExpectedOutput: `{"tagged_var":"a","UNTAGGED_VAR":"b","HTTP_SERVER_ID":1}
+`,
+		},
+		{
+			Name:           "fail",
+			UntaggedFields: FailOnUntagged,
+			ExpectedError:  errors.New("failed to marshal (root): field has no target tag name: structTags.untaggedStruct.UntaggedVar"),
+			ExpectedOutput: "",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+			m.UntaggedFields = testCase.UntaggedFields
+			m.FieldNaming = testCase.FieldNaming
+			b, err := m.Marshal(untaggedStruct{
+				TaggedVar:    "a",
+				UntaggedVar:  "b",
+				HTTPServerID: 1,
+			})
+			assertError(t, testCase.ExpectedError, err)
+			assert.Equal(t, testCase.ExpectedOutput, string(b))
+
+			if err == nil {
+				var output untaggedStruct
+				assert.NoError(t, m.Unmarshal(b, &output))
+				roundTrip, err := m.Marshal(output)
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.ExpectedOutput, string(roundTrip))
+			}
+		})
+	}
+}
+
+func TestCustomMarshaller_UnexportedFields(t *testing.T) {
+	input := unexportedStruct{
+		embeddedStruct: embeddedStruct{IDVar: 1},
+		ExportedVar:    "a",
+		taggedVar:      "b",
+		interfaceVar:   positiveNumber{Value: 1},
+		childVar:       childStruct{},
+		namedEmbedVar:  embeddedStruct{IDVar: 2},
+	}
+
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	b, err := m.Marshal(input)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id_var":1,"name_var":"","LabelVar":"","exported_var":"a"}
+`, string(b))
+
+	var output unexportedStruct
+	assert.NoError(t, m.Unmarshal([]byte(`{"id_var":1,"exported_var":"a","tagged_var":"b","interface_var":1}`), &output))
+	assert.Equal(t, unexportedStruct{
+		embeddedStruct: embeddedStruct{IDVar: 1},
+		ExportedVar:    "a",
+	}, output)
+
+	var reported []string
+	m.OnUnexportedField = func(t reflect.Type, field reflect.StructField) error {
+		reported = append(reported, t.Name()+"."+field.Name)
+		return nil
+	}
+	_, err = m.Marshal(input)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"unexportedStruct.taggedVar",
+		"unexportedStruct.interfaceVar",
+		"unexportedStruct.childVar",
+		"unexportedStruct.namedEmbedVar",
+	}, reported)
+
+	m.OnUnexportedField = func(t reflect.Type, field reflect.StructField) error {
+		return fmt.Errorf("unexpected unexported field %s", field.Name)
+	}
+	_, err = m.Marshal(input)
+	assert.EqualError(t, err, "failed to marshal (root): unexpected unexported field taggedVar")
+	err = m.Unmarshal([]byte(`{}`), &output)
+	assert.EqualError(t, err, "failed to unmarshal (root): unexpected unexported field taggedVar")
+}
+
+func TestCustomMarshaller_FallbackTags(t *testing.T) {
+	testCases := []struct {
+		Name           string
+		TargetTag      string
+		FallbackTags   []string
+		Input          fallbackStruct
+		ExpectedOutput string
+	}{
+		{
+			Name:      "no fallback",
+			TargetTag: "api",
+			Input:     fallbackStruct{APIVar: "a", JSONVar: "b", IgnoredVar: "c", ExposedVar: "d", YAMLVar: "e", BareVar: "f"},
+			ExpectedOutput: `{"api_var":"a","JSONVar":"b","exposed_var":"d","YAMLVar":"e","BareVar":"f"}
+`,
+		},
+		{
+			Name:         "json fallback",
+			TargetTag:    "api",
+			FallbackTags: []string{"json"},
+			Input:        fallbackStruct{APIVar: "a", JSONVar: "b", IgnoredVar: "c", ExposedVar: "d", YAMLVar: "e", BareVar: "f"},
+			ExpectedOutput: `{"api_var":"a","jsonVar":"b","exposed_var":"d","BareVar":"f"}
+`,
+		},
+		{
+			Name:         "fallback options",
+			TargetTag:    "api",
+			FallbackTags: []string{"json"},
+			Input:        fallbackStruct{APIVar: "a"},
+			ExpectedOutput: `{"api_var":"a","exposed_var":"","BareVar":""}
+`,
+		},
+		{
+			Name:         "fallback order",
+			TargetTag:    "api",
+			FallbackTags: []string{"yaml", "json"},
+			Input:        fallbackStruct{APIVar: "a", JSONVar: "b", IgnoredVar: "c", ExposedVar: "d", YAMLVar: "e", BareVar: "f"},
+			ExpectedOutput: `{"api_var":"a","jsonVar":"b","exposed_var":"d","yaml_var":"e","BareVar":"f"}
+`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			m := NewCustomMarshaller(testCase.TargetTag, ignoreTagWithValue)
+			m.FallbackTags = testCase.FallbackTags
+			b, err := m.Marshal(testCase.Input)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.ExpectedOutput, string(b))
+
+			var output fallbackStruct
+			assert.NoError(t, m.Unmarshal(b, &output))
+			roundTrip, err := m.Marshal(output)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.ExpectedOutput, string(roundTrip))
+		})
+	}
+}
+
+func TestCustomMarshaller_NilValues(t *testing.T) {
+	testCases := []struct {
+		Name            string
+		NilSliceAsEmpty bool
+		NilMapAsEmpty   bool
+		ExpectedOutput  string
+	}{
+		{
+			Name: "null",
+			ExpectedOutput: `{"ptr_var":null,"struct_ptr_var":null,"slice_var":null,"bytes_var":null,"map_var":null,"any_var":null,"any_slice_var":[null,null]}
+`,
+		},
+		{
+			Name:            "nil slice as empty",
+			NilSliceAsEmpty: true,
+			ExpectedOutput: `{"ptr_var":null,"struct_ptr_var":null,"slice_var":[],"bytes_var":"","map_var":null,"any_var":null,"any_slice_var":[null,null]}
+`,
+		},
+		{
+			Name:          "nil map as empty",
+			NilMapAsEmpty: true,
+			ExpectedOutput: `{"ptr_var":null,"struct_ptr_var":null,"slice_var":null,"bytes_var":null,"map_var":{},"any_var":null,"any_slice_var":[null,null]}
+`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+			m.NilSliceAsEmpty = testCase.NilSliceAsEmpty
+			m.NilMapAsEmpty = testCase.NilMapAsEmpty
+			b, err := m.Marshal(nilStruct{
+				AnySliceVar: []interface{}{nil, (*int)(nil)},
+			})
+			assert.NoError(t, err)
 			assert.Equal(t, testCase.ExpectedOutput, string(b))
 		})
 	}
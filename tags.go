@@ -0,0 +1,96 @@
+package structTags
+
+import (
+	"reflect"
+	"strings"
+)
+
+// tagOptions is the string following a comma in a struct field's tag, e.g.
+// "omitempty,string".
+type tagOptions string
+
+// parseTag splits a struct field's tag into its name and comma-separated
+// options.
+func parseTag(tag string) (string, tagOptions) {
+	name, options, _ := strings.Cut(tag, ",")
+	return name, tagOptions(options)
+}
+
+// Contains reports whether a comma-separated list of options contains the
+// provided option.
+func (o tagOptions) Contains(option string) bool {
+	s := string(o)
+	for s != "" {
+		var name string
+		name, s, _ = strings.Cut(s, ",")
+		if name == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isZeroer is implemented by types which define their own zero value, such as
+// time.Time. It's respected by the omitzero tag option.
+type isZeroer interface {
+	IsZero() bool
+}
+
+var isZeroerType = reflect.TypeOf((*isZeroer)(nil)).Elem()
+
+// isEmptyValue reports whether v is empty, according to the omitempty rules
+// of encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+
+	return false
+}
+
+// isZeroValue reports whether v is the zero value for its type, preferring the
+// type's own IsZero method when it has one.
+func isZeroValue(v reflect.Value) bool {
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return true
+	}
+	if v.CanInterface() {
+		if v.Type().Implements(isZeroerType) {
+			return v.Interface().(isZeroer).IsZero()
+		}
+		if v.CanAddr() && reflect.PtrTo(v.Type()).Implements(isZeroerType) {
+			return v.Addr().Interface().(isZeroer).IsZero()
+		}
+	}
+
+	return v.IsZero()
+}
+
+// canQuote reports whether the string tag option applies to values of type t.
+func canQuote(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.String:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,361 @@
+package structTags
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// MarshalTOML is like Marshal, but encodes obj as a TOML document, keyed by
+// the same target tag names. obj must marshal into an object.
+//
+// Nested objects become tables, and arrays made up only of objects become
+// arrays of tables. Within each table, plain keys come first, followed by the
+// tables, both in the order the walk reports them: struct fields in
+// declaration order, and map keys sorted. TOML has no null, so keys with null
+// values are left out, and arrays can't hold them.
+func (m *CustomMarshaller) MarshalTOML(obj interface{}) ([]byte, error) {
+	f := &tomlFormat{m: m}
+	err := m.MarshalFormat(f, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	writeTOMLTable(&b, nil, f.root)
+
+	return b.Bytes(), nil
+}
+
+// UnmarshalTOML parses the TOML document data and stores the result in the
+// value pointed to by obj, matching keys to struct fields like Unmarshal.
+//
+// Datetimes are decoded as strings, which time.Time accepts when they have an
+// offset. Infinite and NaN floats can't be decoded.
+func (m *CustomMarshaller) UnmarshalTOML(data []byte, obj interface{}) error {
+	v, err := unmarshalTarget(obj)
+	if err != nil {
+		return err
+	}
+
+	root, err := parseTOML(data)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	err = root.appendJSON(&b)
+	if err != nil {
+		return err
+	}
+
+	d := m.newDecodeState()
+	err = m.unmarshal(d, b.Bytes(), v)
+	if err != nil {
+		return d.wrap(v.Type(), err)
+	}
+
+	return nil
+}
+
+var (
+	errTOMLRoot      = errors.New("TOML documents must be objects")
+	errTOMLNullArray = errors.New("TOML arrays can't hold null")
+)
+
+type tomlNodeKind int
+
+const (
+	tomlScalarNode tomlNodeKind = iota
+	tomlTableNode
+	tomlArrayNode
+)
+
+// tomlNode is a value of a TOML document being encoded. Nil nodes stand for
+// null.
+type tomlNode struct {
+	kind tomlNodeKind
+	// keys and values hold the entries of tables, and values the elements of
+	// arrays.
+	keys   []string
+	values []*tomlNode
+	// text is the encoding of scalars.
+	text string
+}
+
+// isTableArray reports whether n is encoded as an array of tables.
+func (n *tomlNode) isTableArray() bool {
+	if n.kind != tomlArrayNode || len(n.values) == 0 {
+		return false
+	}
+	for _, e := range n.values {
+		if e.kind != tomlTableNode {
+			return false
+		}
+	}
+
+	return true
+}
+
+// tomlFormat is the Format of MarshalTOML, which builds a tree of TOML nodes.
+type tomlFormat struct {
+	m    *CustomMarshaller
+	root *tomlNode
+	// open holds the tables and arrays being built.
+	open []*tomlNode
+}
+
+// add appends n to the innermost open node, or makes it the root.
+func (f *tomlFormat) add(n *tomlNode) error {
+	if len(f.open) == 0 {
+		if n == nil || n.kind != tomlTableNode {
+			return errTOMLRoot
+		}
+		f.root = n
+		return nil
+	}
+	parent := f.open[len(f.open)-1]
+	if n == nil && parent.kind == tomlArrayNode {
+		return errTOMLNullArray
+	}
+	parent.values = append(parent.values, n)
+
+	return nil
+}
+
+func (f *tomlFormat) begin(kind tomlNodeKind) error {
+	n := &tomlNode{kind: kind}
+	err := f.add(n)
+	if err != nil {
+		return err
+	}
+	f.open = append(f.open, n)
+	return nil
+}
+
+func (f *tomlFormat) end() error {
+	f.open = f.open[:len(f.open)-1]
+	return nil
+}
+
+func (f *tomlFormat) BeginObject() error {
+	return f.begin(tomlTableNode)
+}
+
+func (f *tomlFormat) Key(key string) error {
+	key, err := f.m.validUTF8(key)
+	if err != nil {
+		return err
+	}
+	parent := f.open[len(f.open)-1]
+	parent.keys = append(parent.keys, key)
+	return nil
+}
+
+func (f *tomlFormat) EndObject() error {
+	return f.end()
+}
+
+func (f *tomlFormat) BeginArray() error {
+	return f.begin(tomlArrayNode)
+}
+
+func (f *tomlFormat) EndArray() error {
+	return f.end()
+}
+
+func (f *tomlFormat) Scalar(v reflect.Value) error {
+	var text string
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		return f.add(nil)
+	case reflect.Bool:
+		text = strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		text = strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			// TOML integers are 64-bit signed integers.
+			return &UnsupportedValueError{
+				Value: v,
+				Str:   strconv.FormatUint(v.Uint(), 10),
+			}
+		}
+		text = strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		text = formatTOMLFloat(v.Float(), v.Type().Bits())
+	case reflect.String:
+		s, err := f.m.validUTF8(v.String())
+		if err != nil {
+			return err
+		}
+		text = string(appendTOMLString(nil, s))
+	default:
+		return &UnsupportedTypeError{Type: v.Type()}
+	}
+
+	return f.add(&tomlNode{
+		kind: tomlScalarNode,
+		text: text,
+	})
+}
+
+// formatTOMLFloat formats f so that it reads back as a float, rather than an
+// integer.
+func formatTOMLFloat(f float64, bits int) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+
+	s := strconv.FormatFloat(f, 'f', -1, bits)
+	if !strings.ContainsRune(s, '.') {
+		s += ".0"
+	}
+
+	return s
+}
+
+// appendTOMLString appends s to dst as a TOML basic string.
+func appendTOMLString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			dst = append(dst, '\\', byte(r))
+		case '\b':
+			dst = append(dst, '\\', 'b')
+		case '\t':
+			dst = append(dst, '\\', 't')
+		case '\n':
+			dst = append(dst, '\\', 'n')
+		case '\f':
+			dst = append(dst, '\\', 'f')
+		case '\r':
+			dst = append(dst, '\\', 'r')
+		default:
+			if r < 0x20 || r == 0x7f {
+				dst = append(dst, fmt.Sprintf(`\u%04X`, r)...)
+				continue
+			}
+			dst = append(dst, string(r)...)
+		}
+	}
+
+	return append(dst, '"')
+}
+
+// appendTOMLKey appends key to dst, quoted unless it's a valid bare key.
+func appendTOMLKey(dst []byte, key string) []byte {
+	if key == "" {
+		return appendTOMLString(dst, key)
+	}
+	for i := 0; i < len(key); i++ {
+		if !isTOMLBareKeyByte(key[i]) {
+			return appendTOMLString(dst, key)
+		}
+	}
+
+	return append(dst, key...)
+}
+
+func isTOMLBareKeyByte(c byte) bool {
+	return (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '_' || c == '-'
+}
+
+// writeTOMLTable writes the entries of the table t, found at path. Plain keys
+// are written first, since any key following a table header belongs to that
+// table.
+func writeTOMLTable(b *bytes.Buffer, path []string, t *tomlNode) {
+	for i, key := range t.keys {
+		v := t.values[i]
+		if v == nil || v.kind == tomlTableNode || v.isTableArray() {
+			continue
+		}
+		b.Write(appendTOMLKey(nil, key))
+		b.WriteString(" = ")
+		writeTOMLInline(b, v)
+		b.WriteByte('\n')
+	}
+
+	for i, key := range t.keys {
+		v := t.values[i]
+		if v == nil {
+			continue
+		}
+		p := append(path[:len(path):len(path)], key)
+		if v.kind == tomlTableNode {
+			writeTOMLHeader(b, "[", p, "]")
+			writeTOMLTable(b, p, v)
+		} else if v.isTableArray() {
+			for _, e := range v.values {
+				writeTOMLHeader(b, "[[", p, "]]")
+				writeTOMLTable(b, p, e)
+			}
+		}
+	}
+}
+
+// writeTOMLHeader writes a table header for path, separated from anything
+// before it by a blank line.
+func writeTOMLHeader(b *bytes.Buffer, open string, path []string, close string) {
+	if b.Len() > 0 {
+		b.WriteByte('\n')
+	}
+	b.WriteString(open)
+	for i, key := range path {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.Write(appendTOMLKey(nil, key))
+	}
+	b.WriteString(close)
+	b.WriteByte('\n')
+}
+
+// writeTOMLInline writes the value v on a single line, using inline tables
+// for any tables inside of it.
+func writeTOMLInline(b *bytes.Buffer, v *tomlNode) {
+	switch v.kind {
+	case tomlScalarNode:
+		b.WriteString(v.text)
+	case tomlArrayNode:
+		b.WriteByte('[')
+		for i, e := range v.values {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeTOMLInline(b, e)
+		}
+		b.WriteByte(']')
+	case tomlTableNode:
+		b.WriteByte('{')
+		first := true
+		for i, key := range v.keys {
+			if v.values[i] == nil {
+				continue
+			}
+			if first {
+				b.WriteByte(' ')
+			} else {
+				b.WriteString(", ")
+			}
+			first = false
+			b.Write(appendTOMLKey(nil, key))
+			b.WriteString(" = ")
+			writeTOMLInline(b, v.values[i])
+		}
+		if !first {
+			b.WriteByte(' ')
+		}
+		b.WriteByte('}')
+	}
+}
@@ -0,0 +1,360 @@
+package structTags
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+type tomlServer struct {
+	Name  string   `custom:"name"`
+	Ports []int    `custom:"ports"`
+	Owner *tomlRef `custom:"owner"`
+}
+
+type tomlRef struct {
+	ID    int    `custom:"id"`
+	Label string `custom:"label"`
+}
+
+type tomlConfig struct {
+	Title   string            `custom:"title"`
+	Ignored string            `custom:"-"`
+	Ratio   float64           `custom:"ratio"`
+	Enabled bool              `custom:"enabled"`
+	Tags    map[string]string `custom:"tags"`
+	Servers []tomlServer      `custom:"servers"`
+	Default tomlRef           `custom:"default"`
+	Refs    []tomlRef         `custom:"refs,omitempty"`
+}
+
+func TestCustomMarshaller_MarshalTOML(t *testing.T) {
+	testCases := []struct {
+		Name           string
+		Input          any
+		Configure      func(m *CustomMarshaller)
+		ExpectedError  error
+		ExpectedOutput string
+	}{
+		{
+			Name: "tables and arrays of tables",
+			Input: tomlConfig{
+				Title:   "example",
+				Ignored: "ignored",
+				Ratio:   2,
+				Tags:    map[string]string{"b": "2", "a b": "1"},
+				Servers: []tomlServer{
+					{Name: "alpha", Ports: []int{80, 443}, Owner: &tomlRef{ID: 1}},
+					{Name: "beta"},
+				},
+				Default: tomlRef{ID: 7, Label: "seven"},
+			},
+			ExpectedOutput: `title = "example"
+ratio = 2.0
+enabled = false
+
+[tags]
+"a b" = "1"
+b = "2"
+
+[[servers]]
+name = "alpha"
+ports = [80, 443]
+
+[servers.owner]
+id = 1
+label = ""
+
+[[servers]]
+name = "beta"
+
+[default]
+id = 7
+label = "seven"
+`,
+		},
+		{
+			Name: "inline tables inside arrays",
+			Input: map[string]interface{}{
+				"mixed":  []interface{}{1, "two", map[string]int{"three": 3}},
+				"nested": [][]tomlRef{{{ID: 1}}},
+				"empty":  map[string]int{},
+			},
+			ExpectedOutput: `mixed = [1, "two", { three = 3 }]
+nested = [[{ id = 1, label = "" }]]
+
+[empty]
+`,
+		},
+		{
+			Name:           "escaped strings",
+			Input:          map[string]string{"s": "a\"b\\c\td\x01"},
+			ExpectedOutput: `s = "a\"b\\c\td\u0001"` + "\n",
+		},
+		{
+			Name:           "special floats",
+			Input:          map[string]float64{"a": math.Inf(1), "b": math.Inf(-1), "c": math.NaN(), "d": 1e21},
+			ExpectedOutput: "a = inf\nb = -inf\nc = nan\nd = 1000000000000000000000.0\n",
+		},
+		{
+			Name:           "null values are left out",
+			Input:          map[string]*int{"a": nil},
+			ExpectedOutput: "",
+		},
+		{
+			Name:          "null array elements",
+			Input:         map[string][]*int{"a": {nil}},
+			ExpectedError: errors.New(`failed to marshal ["a"][0]: TOML arrays can't hold null`),
+		},
+		{
+			Name:          "non-object root",
+			Input:         []int{1},
+			ExpectedError: errors.New("failed to marshal (root): TOML documents must be objects"),
+		},
+		{
+			Name:          "uint64 overflow",
+			Input:         map[string]uint64{"a": math.MaxUint64},
+			ExpectedError: errors.New(`failed to marshal ["a"]: unsupported value: 18446744073709551615`),
+		},
+		{
+			Name:  "rejected invalid UTF-8",
+			Input: map[string]string{"key": "a\xffb"},
+			Configure: func(m *CustomMarshaller) {
+				m.InvalidUTF8 = RejectInvalidUTF8
+			},
+			ExpectedError: errors.New(`failed to marshal ["key"]: string was not valid UTF-8: "a\xffb"`),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+			if testCase.Configure != nil {
+				testCase.Configure(m)
+			}
+			b, err := m.MarshalTOML(testCase.Input)
+			assertError(t, testCase.ExpectedError, err)
+			assert.Equal(t, testCase.ExpectedOutput, string(b))
+		})
+	}
+}
+
+func TestCustomMarshaller_UnmarshalTOML(t *testing.T) {
+	input := `# A comment.
+title = 'example' # Trailing comment.
+ratio = 1_000.5
+enabled = true
+Ignored = "ignored"
+tags = { "a b" = "1", b = """
+2""" }
+
+[[servers]]
+name = "alpha"
+ports = [
+  80,
+  0x1bb, # 443
+]
+owner.id = 1
+owner.label = """\
+    one \
+    two"""
+
+[[servers]]
+name = '''b'eta'''
+
+[default]
+id = +7
+label = "seven"
+`
+
+	var output tomlConfig
+	err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).UnmarshalTOML([]byte(input), &output)
+	assert.NoError(t, err)
+	assert.Equal(t, tomlConfig{
+		Title:   "example",
+		Ratio:   1000.5,
+		Enabled: true,
+		Tags:    map[string]string{"a b": "1", "b": "2"},
+		Servers: []tomlServer{
+			{Name: "alpha", Ports: []int{80, 443}, Owner: &tomlRef{ID: 1, Label: "one two"}},
+			{Name: "b'eta"},
+		},
+		Default: tomlRef{ID: 7, Label: "seven"},
+	}, output)
+}
+
+func TestCustomMarshaller_UnmarshalTOML_Values(t *testing.T) {
+	testCases := []struct {
+		Name           string
+		Input          string
+		ExpectedError  error
+		ExpectedOutput map[string]interface{}
+	}{
+		{
+			Name:           "integers",
+			Input:          "a = -17\nb = 0o17\nc = 0b101\nd = 0xdead_BEEF\ne = 0",
+			ExpectedOutput: map[string]interface{}{"a": -17.0, "b": 15.0, "c": 5.0, "d": 3735928559.0, "e": 0.0},
+		},
+		{
+			Name:           "floats",
+			Input:          "a = 6.626e-34\nb = -0.5\nc = 5E+2\nd = 9_1.0",
+			ExpectedOutput: map[string]interface{}{"a": 6.626e-34, "b": -0.5, "c": 500.0, "d": 91.0},
+		},
+		{
+			Name:  "datetimes",
+			Input: "a = 1979-05-27T07:32:00Z\nb = 1979-05-27 00:32:00.999-07:00\nc = 1979-05-27t07:32:00\nd = 1979-05-27\ne = 07:32:00",
+			ExpectedOutput: map[string]interface{}{
+				"a": "1979-05-27T07:32:00Z",
+				"b": "1979-05-27T00:32:00.999-07:00",
+				"c": "1979-05-27T07:32:00",
+				"d": "1979-05-27",
+				"e": "07:32:00",
+			},
+		},
+		{
+			Name:           "multi-line strings",
+			Input:          "a = \"\"\"\none\r\ntwo\"\"\"\"\"\nb = '''\n\\n'''",
+			ExpectedOutput: map[string]interface{}{"a": "one\ntwo\"\"", "b": "\\n"},
+		},
+		{
+			Name:           "implicit tables",
+			Input:          "[a.b.c]\nd = 1\n[a]\ne = 2\n[a.b]\nf = 3",
+			ExpectedOutput: map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": map[string]interface{}{"d": 1.0}, "f": 3.0}, "e": 2.0}},
+		},
+		{
+			Name:           "sub-tables of arrays of tables",
+			Input:          "[[a]]\n[a.b]\nc = 1\n[[a]]\n[[a.d]]",
+			ExpectedOutput: map[string]interface{}{"a": []interface{}{map[string]interface{}{"b": map[string]interface{}{"c": 1.0}}, map[string]interface{}{"d": []interface{}{map[string]interface{}{}}}}},
+		},
+		{
+			Name:          "duplicate keys",
+			Input:         "a = 1\n\na = 2",
+			ExpectedError: errors.New("toml: line 3: key a is already defined"),
+		},
+		{
+			Name:          "redefined tables",
+			Input:         "[a]\nb = 1\n[a]",
+			ExpectedError: errors.New("toml: line 3: table a is already defined"),
+		},
+		{
+			Name:          "tables redefined by headers after dotted keys",
+			Input:         "a.b = 1\n[a]",
+			ExpectedError: errors.New("toml: line 2: table a is already defined"),
+		},
+		{
+			Name:          "tables extended by dotted keys",
+			Input:         "[a.b]\n[a]\nb.c = 1",
+			ExpectedError: errors.New("toml: line 3: cannot add to table b with dotted keys"),
+		},
+		{
+			Name:          "inline tables extended by headers",
+			Input:         "a = { b = { c = 1 } }\n[a.b.d]",
+			ExpectedError: errors.New("toml: line 2: cannot add to inline table a"),
+		},
+		{
+			Name:          "static arrays extended by headers",
+			Input:         "a = []\n[[a]]",
+			ExpectedError: errors.New("toml: line 2: key a is already defined, and isn't an array of tables"),
+		},
+		{
+			Name:          "leading zeros",
+			Input:         "a = 01",
+			ExpectedError: errors.New("toml: line 1: invalid value 01"),
+		},
+		{
+			Name:          "misplaced underscores",
+			Input:         "a = 1__0",
+			ExpectedError: errors.New("toml: line 1: invalid value 1__0"),
+		},
+		{
+			Name:          "underscores next to exponents",
+			Input:         "a = 1_e5",
+			ExpectedError: errors.New("toml: line 1: invalid value 1_e5"),
+		},
+		{
+			Name:          "invalid datetimes",
+			Input:         "a = 1979-13-27",
+			ExpectedError: errors.New("toml: line 1: invalid datetime 1979-13-27"),
+		},
+		{
+			Name:          "unterminated strings",
+			Input:         "a = \"b\nc = 1",
+			ExpectedError: errors.New("toml: line 1: unterminated string"),
+		},
+		{
+			Name:          "invalid escapes",
+			Input:         `a = "\x"`,
+			ExpectedError: errors.New(`toml: line 1: invalid escape sequence \'x'`),
+		},
+		{
+			Name:          "missing newlines",
+			Input:         "a = 1 b = 2",
+			ExpectedError: errors.New(`toml: line 1: expected end of line, found 'b'`),
+		},
+		{
+			Name:          "newlines in inline tables",
+			Input:         "a = { b = 1,\n c = 2 }",
+			ExpectedError: errors.New(`toml: line 1: expected a key, found end of line`),
+		},
+		{
+			Name:          "missing values",
+			Input:         "a =\n",
+			ExpectedError: errors.New(`toml: line 1: expected a value, found end of line`),
+		},
+		{
+			Name:          "invalid UTF-8",
+			Input:         "a = 1\nb = \"\xff\"",
+			ExpectedError: errors.New("toml: line 2: invalid UTF-8"),
+		},
+		{
+			Name:          "non-finite floats",
+			Input:         "a = -inf",
+			ExpectedError: errors.New("cannot unmarshal TOML float -inf"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			var output map[string]interface{}
+			err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).UnmarshalTOML([]byte(testCase.Input), &output)
+			assertError(t, testCase.ExpectedError, err)
+			assert.Equal(t, testCase.ExpectedOutput, output)
+		})
+	}
+}
+
+func TestCustomMarshaller_UnmarshalTOML_Errors(t *testing.T) {
+	var output tomlConfig
+	err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).UnmarshalTOML([]byte("[[servers]]\nports = [\"80\"]"), &output)
+	assert.EqualError(t, err, "failed to unmarshal servers[0].ports[0]: cannot unmarshal string into int")
+
+	var syntaxErr *TOMLSyntaxError
+	err = NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).UnmarshalTOML([]byte("["), &output)
+	assert.True(t, errors.As(err, &syntaxErr))
+	assert.Equal(t, 1, syntaxErr.Line)
+
+	err = NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).UnmarshalTOML(nil, output)
+	assert.Error(t, err)
+}
+
+func TestCustomMarshaller_TOMLRoundTrip(t *testing.T) {
+	input := tomlConfig{
+		Title:   "line\nbreak \"quoted\"",
+		Ratio:   0.1,
+		Enabled: true,
+		Tags:    map[string]string{"": "empty", "dotted.key": "v"},
+		Servers: []tomlServer{{Name: "alpha", Ports: []int{1, 2}, Owner: &tomlRef{ID: -1, Label: "\x7f"}}},
+		Default: tomlRef{ID: 1},
+		Refs:    []tomlRef{{ID: 2}, {ID: 3}},
+	}
+
+	m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+	b, err := m.MarshalTOML(input)
+	assert.NoError(t, err)
+
+	var output tomlConfig
+	err = m.UnmarshalTOML(b, &output)
+	assert.NoError(t, err)
+	assert.Equal(t, input, output)
+}
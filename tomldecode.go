@@ -0,0 +1,896 @@
+package structTags
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// TOMLSyntaxError describes malformed TOML, including documents which define
+// the same key or table more than once.
+type TOMLSyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *TOMLSyntaxError) Error() string {
+	return fmt.Sprintf("toml: line %d: %s", e.Line, e.Msg)
+}
+
+// tomlTableKind records how a table was defined, which decides whether it may
+// be defined again.
+type tomlTableKind int
+
+const (
+	// implicitTable is created as the parent of a table header, and may be
+	// defined by a header of its own later on.
+	implicitTable tomlTableKind = iota
+	// headerTable is defined by a table header, or is the root table.
+	headerTable
+	// dottedTable is defined by dotted keys, which may add to it.
+	dottedTable
+	// inlineTable is defined inline, and can't be added to.
+	inlineTable
+)
+
+// tomlTable is a table of a TOML document being decoded. Values are strings,
+// int64s, float64s, bools, tomlDatetimes, []interface{}, *tomlTable and
+// *tomlTableArray.
+type tomlTable struct {
+	kind   tomlTableKind
+	values map[string]interface{}
+}
+
+// tomlTableArray is an array of tables, defined by [[headers]].
+type tomlTableArray struct {
+	tables []*tomlTable
+}
+
+// tomlDatetime is a datetime, date or time, normalized so that datetimes with
+// an offset are in RFC 3339 format.
+type tomlDatetime string
+
+func newTOMLTable(kind tomlTableKind) *tomlTable {
+	return &tomlTable{
+		kind:   kind,
+		values: map[string]interface{}{},
+	}
+}
+
+// freeze marks t as an inline table, along with the tables defined inside of
+// it with dotted keys.
+func (t *tomlTable) freeze() {
+	t.kind = inlineTable
+	for _, v := range t.values {
+		if child, ok := v.(*tomlTable); ok && child.kind == dottedTable {
+			child.freeze()
+		}
+	}
+}
+
+// appendJSON writes the table as a JSON object, with sorted keys.
+func (t *tomlTable) appendJSON(b *bytes.Buffer) error {
+	keys := make([]string, 0, len(t.values))
+	for key := range t.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	b.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		err := appendTOMLJSON(b, key)
+		if err != nil {
+			return err
+		}
+		b.WriteByte(':')
+		err = appendTOMLJSON(b, t.values[key])
+		if err != nil {
+			return err
+		}
+	}
+	b.WriteByte('}')
+
+	return nil
+}
+
+// appendTOMLJSON writes the decoded TOML value v as JSON.
+func appendTOMLJSON(b *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case *tomlTable:
+		return v.appendJSON(b)
+	case *tomlTableArray:
+		b.WriteByte('[')
+		for i, t := range v.tables {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			err := t.appendJSON(b)
+			if err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	case []interface{}:
+		b.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			err := appendTOMLJSON(b, e)
+			if err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	case int64:
+		b.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return fmt.Errorf("cannot unmarshal TOML float %s", formatTOMLFloat(v, 64))
+		}
+		b.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case string:
+		s, _ := json.Marshal(v)
+		b.Write(s)
+	case tomlDatetime:
+		s, _ := json.Marshal(string(v))
+		b.Write(s)
+	}
+
+	return nil
+}
+
+// tomlParser parses TOML 1.0 documents.
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+	root *tomlTable
+	// current is the table which key/value pairs are added to.
+	current *tomlTable
+}
+
+// parseTOML parses the TOML document data into its root table.
+func parseTOML(data []byte) (*tomlTable, error) {
+	p := &tomlParser{
+		src:  string(data),
+		line: 1,
+		root: newTOMLTable(headerTable),
+	}
+	p.current = p.root
+
+	if !utf8.Valid(data) {
+		for i := 0; i < len(data); {
+			r, size := utf8.DecodeRune(data[i:])
+			if r == utf8.RuneError && size == 1 {
+				p.line = 1 + bytes.Count(data[:i], []byte("\n"))
+				return nil, p.errorf("invalid UTF-8")
+			}
+			i += size
+		}
+	}
+
+	for {
+		err := p.skipBlank()
+		if err != nil {
+			return nil, err
+		}
+		if p.eof() {
+			return p.root, nil
+		}
+
+		if p.peek() == '[' {
+			err = p.parseHeader()
+		} else {
+			err = p.parseKeyValue(p.current)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = p.endOfLine()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return &TOMLSyntaxError{
+		Line: p.line,
+		Msg:  fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *tomlParser) hasPrefix(prefix string) bool {
+	return strings.HasPrefix(p.src[p.pos:], prefix)
+}
+
+// describe returns the next character, for error messages.
+func (p *tomlParser) describe() string {
+	if p.eof() {
+		return "end of file"
+	}
+	r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+	if r == '\n' || r == '\r' {
+		return "end of line"
+	}
+
+	return strconv.QuoteRune(r)
+}
+
+// skipSpace skips spaces and tabs.
+func (p *tomlParser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// skipComment skips a comment up to the end of the line.
+func (p *tomlParser) skipComment() error {
+	if p.peek() != '#' {
+		return nil
+	}
+	for !p.eof() && p.peek() != '\n' {
+		c := p.peek()
+		if (c < 0x20 && c != '\t' && c != '\r') || c == 0x7f {
+			return p.errorf("control character %U in comment", rune(c))
+		}
+		p.pos++
+	}
+
+	return nil
+}
+
+// skipNewline skips a newline, reporting whether there was one.
+func (p *tomlParser) skipNewline() bool {
+	if p.hasPrefix("\n") {
+		p.pos++
+	} else if p.hasPrefix("\r\n") {
+		p.pos += 2
+	} else {
+		return false
+	}
+	p.line++
+
+	return true
+}
+
+// skipBlank skips whitespace, comments and newlines.
+func (p *tomlParser) skipBlank() error {
+	for {
+		p.skipSpace()
+		err := p.skipComment()
+		if err != nil {
+			return err
+		}
+		if !p.skipNewline() {
+			return nil
+		}
+	}
+}
+
+// endOfLine expects the end of a statement.
+func (p *tomlParser) endOfLine() error {
+	p.skipSpace()
+	err := p.skipComment()
+	if err != nil {
+		return err
+	}
+	if !p.eof() && !p.skipNewline() {
+		return p.errorf("expected end of line, found %s", p.describe())
+	}
+
+	return nil
+}
+
+// parseHeader parses a [table] or [[array of tables]] header.
+func (p *tomlParser) parseHeader() error {
+	array := p.hasPrefix("[[")
+	if array {
+		p.pos += 2
+	} else {
+		p.pos++
+	}
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if array {
+		if !p.hasPrefix("]]") {
+			return p.errorf("expected ]] after table name, found %s", p.describe())
+		}
+		p.pos += 2
+		return p.defineTableArray(keys)
+	}
+	if p.peek() != ']' {
+		return p.errorf("expected ] after table name, found %s", p.describe())
+	}
+	p.pos++
+
+	return p.defineTable(keys)
+}
+
+// descend returns the table at key inside t, creating it when it doesn't
+// exist. Headers may descend into any table which isn't inline, and into the
+// last table of arrays of tables, but dotted keys may only add to tables
+// defined by dotted keys.
+func (p *tomlParser) descend(t *tomlTable, keys []string, i int, header bool) (*tomlTable, error) {
+	v, ok := t.values[keys[i]]
+	if !ok {
+		kind := dottedTable
+		if header {
+			kind = implicitTable
+		}
+		child := newTOMLTable(kind)
+		t.values[keys[i]] = child
+		return child, nil
+	}
+
+	name := strings.Join(keys[:i+1], ".")
+	switch v := v.(type) {
+	case *tomlTable:
+		if v.kind == inlineTable {
+			return nil, p.errorf("cannot add to inline table %s", name)
+		}
+		if !header && v.kind != dottedTable {
+			return nil, p.errorf("cannot add to table %s with dotted keys", name)
+		}
+		return v, nil
+	case *tomlTableArray:
+		if !header {
+			return nil, p.errorf("cannot add to array of tables %s with dotted keys", name)
+		}
+		return v.tables[len(v.tables)-1], nil
+	}
+
+	return nil, p.errorf("key %s is already defined as a value", name)
+}
+
+// defineTable makes the table at keys the current table.
+func (p *tomlParser) defineTable(keys []string) error {
+	t := p.root
+	var err error
+	for i := range keys[:len(keys)-1] {
+		t, err = p.descend(t, keys, i, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	last := keys[len(keys)-1]
+	v, ok := t.values[last]
+	if !ok {
+		p.current = newTOMLTable(headerTable)
+		t.values[last] = p.current
+		return nil
+	}
+	child, ok := v.(*tomlTable)
+	if !ok || child.kind != implicitTable {
+		return p.errorf("table %s is already defined", strings.Join(keys, "."))
+	}
+	child.kind = headerTable
+	p.current = child
+
+	return nil
+}
+
+// defineTableArray appends a table to the array of tables at keys, and makes
+// it the current table.
+func (p *tomlParser) defineTableArray(keys []string) error {
+	t := p.root
+	var err error
+	for i := range keys[:len(keys)-1] {
+		t, err = p.descend(t, keys, i, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	last := keys[len(keys)-1]
+	v, ok := t.values[last]
+	if !ok {
+		v = &tomlTableArray{}
+		t.values[last] = v
+	}
+	array, ok := v.(*tomlTableArray)
+	if !ok {
+		return p.errorf("key %s is already defined, and isn't an array of tables", strings.Join(keys, "."))
+	}
+	p.current = newTOMLTable(headerTable)
+	array.tables = append(array.tables, p.current)
+
+	return nil
+}
+
+// parseKeyValue parses a key/value pair into the table t.
+func (p *tomlParser) parseKeyValue(t *tomlTable) error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if p.peek() != '=' {
+		return p.errorf("expected = after key, found %s", p.describe())
+	}
+	p.pos++
+	p.skipSpace()
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	for i := range keys[:len(keys)-1] {
+		t, err = p.descend(t, keys, i, false)
+		if err != nil {
+			return err
+		}
+	}
+	last := keys[len(keys)-1]
+	if _, ok := t.values[last]; ok {
+		return p.errorf("key %s is already defined", strings.Join(keys, "."))
+	}
+	t.values[last] = value
+
+	return nil
+}
+
+// parseKey parses a possibly dotted key, along with the whitespace around it.
+func (p *tomlParser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace()
+		var key string
+		var err error
+		switch p.peek() {
+		case '"':
+			key, err = p.parseBasicString()
+		case '\'':
+			key, err = p.parseLiteralString()
+		default:
+			start := p.pos
+			for !p.eof() && isTOMLBareKeyByte(p.peek()) {
+				p.pos++
+			}
+			if p.pos == start {
+				return nil, p.errorf("expected a key, found %s", p.describe())
+			}
+			key = p.src[start:p.pos]
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+
+		p.skipSpace()
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+// parseValue parses a value.
+func (p *tomlParser) parseValue() (interface{}, error) {
+	switch c := p.peek(); {
+	case p.hasPrefix(`"""`):
+		return p.parseMultilineBasicString()
+	case c == '"':
+		return p.parseBasicString()
+	case p.hasPrefix("'''"):
+		return p.parseMultilineLiteralString()
+	case c == '\'':
+		return p.parseLiteralString()
+	case c == '[':
+		return p.parseArray()
+	case c == '{':
+		return p.parseInlineTable()
+	case c == 't' || c == 'f':
+		for _, keyword := range []string{"true", "false"} {
+			if p.hasPrefix(keyword) {
+				p.pos += len(keyword)
+				return keyword == "true", nil
+			}
+		}
+	case c == '+' || c == '-' || c == 'i' || c == 'n' || (c >= '0' && c <= '9'):
+		return p.parseNumberOrDatetime()
+	}
+
+	return nil, p.errorf("expected a value, found %s", p.describe())
+}
+
+// parseArray parses an array, which may span several lines.
+func (p *tomlParser) parseArray() (interface{}, error) {
+	p.pos++
+	array := []interface{}{}
+	for {
+		err := p.skipBlank()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return array, nil
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, value)
+
+		err = p.skipBlank()
+		if err != nil {
+			return nil, err
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return array, nil
+		default:
+			return nil, p.errorf("expected , or ] in array, found %s", p.describe())
+		}
+	}
+}
+
+// parseInlineTable parses an inline table, which must fit on a single line.
+func (p *tomlParser) parseInlineTable() (interface{}, error) {
+	p.pos++
+	t := newTOMLTable(dottedTable)
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.pos++
+		t.freeze()
+		return t, nil
+	}
+	for {
+		err := p.parseKeyValue(t)
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			t.freeze()
+			return t, nil
+		default:
+			return nil, p.errorf("expected , or } in inline table, found %s", p.describe())
+		}
+	}
+}
+
+// parseBasicString parses a "basic string".
+func (p *tomlParser) parseBasicString() (string, error) {
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.peek()
+		switch {
+		case c == '"':
+			p.pos++
+			return b.String(), nil
+		case c == '\\':
+			err := p.parseEscape(&b)
+			if err != nil {
+				return "", err
+			}
+		case c == '\n' || c == '\r':
+			return "", p.errorf("unterminated string")
+		case (c < 0x20 && c != '\t') || c == 0x7f:
+			return "", p.errorf("control character %U in string", rune(c))
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// parseMultilineBasicString parses a """multi-line basic string""".
+func (p *tomlParser) parseMultilineBasicString() (string, error) {
+	p.pos += 3
+	p.skipNewline()
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.peek()
+		switch {
+		case p.hasPrefix(`"""`):
+			// Up to two quotes may directly precede the closing delimiter.
+			quotes := 3
+			for quotes < 5 && p.pos+quotes < len(p.src) && p.src[p.pos+quotes] == '"' {
+				quotes++
+			}
+			b.WriteString(strings.Repeat(`"`, quotes-3))
+			p.pos += quotes
+			return b.String(), nil
+		case c == '\\':
+			// A backslash at the end of a line trims the whitespace up to the
+			// next non-whitespace character.
+			rest := strings.TrimLeft(p.src[p.pos+1:], " \t")
+			if strings.HasPrefix(rest, "\n") || strings.HasPrefix(rest, "\r\n") {
+				p.pos = len(p.src) - len(rest)
+				for {
+					p.skipSpace()
+					if !p.skipNewline() {
+						break
+					}
+				}
+				continue
+			}
+			err := p.parseEscape(&b)
+			if err != nil {
+				return "", err
+			}
+		case p.skipNewline():
+			b.WriteByte('\n')
+		case (c < 0x20 && c != '\t') || c == 0x7f:
+			return "", p.errorf("control character %U in string", rune(c))
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// parseEscape parses an escape sequence inside a basic string.
+func (p *tomlParser) parseEscape(b *strings.Builder) error {
+	p.pos++
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case '"':
+		b.WriteByte('"')
+	case '\\':
+		b.WriteByte('\\')
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if p.pos+size > len(p.src) {
+			return p.errorf("invalid escape sequence")
+		}
+		n, err := strconv.ParseUint(p.src[p.pos:p.pos+size], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(n)) {
+			return p.errorf("invalid escape sequence \\%c%s", c, p.src[p.pos:p.pos+size])
+		}
+		b.WriteRune(rune(n))
+		p.pos += size
+	default:
+		p.pos--
+		return p.errorf("invalid escape sequence \\%s", p.describe())
+	}
+
+	return nil
+}
+
+// parseLiteralString parses a 'literal string'.
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.pos++
+	start := p.pos
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.peek()
+		switch {
+		case c == '\'':
+			p.pos++
+			return p.src[start : p.pos-1], nil
+		case c == '\n' || c == '\r':
+			return "", p.errorf("unterminated string")
+		case (c < 0x20 && c != '\t') || c == 0x7f:
+			return "", p.errorf("control character %U in string", rune(c))
+		}
+		p.pos++
+	}
+}
+
+// parseMultilineLiteralString parses a multi-line literal string, delimited
+// by three single quotes.
+func (p *tomlParser) parseMultilineLiteralString() (string, error) {
+	p.pos += 3
+	p.skipNewline()
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.peek()
+		switch {
+		case p.hasPrefix("'''"):
+			quotes := 3
+			for quotes < 5 && p.pos+quotes < len(p.src) && p.src[p.pos+quotes] == '\'' {
+				quotes++
+			}
+			b.WriteString(strings.Repeat("'", quotes-3))
+			p.pos += quotes
+			return b.String(), nil
+		case p.skipNewline():
+			b.WriteByte('\n')
+		case (c < 0x20 && c != '\t') || c == 0x7f:
+			return "", p.errorf("control character %U in string", rune(c))
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// parseNumberOrDatetime parses an integer, a float or a datetime.
+func (p *tomlParser) parseNumberOrDatetime() (interface{}, error) {
+	start := p.pos
+	for !p.eof() && isTOMLValueByte(p.peek()) {
+		p.pos++
+	}
+	// Dates may be separated from times by a space.
+	if p.pos-start == 10 && p.src[start+4] == '-' && p.pos+1 < len(p.src) && p.peek() == ' ' && isDigit(p.src[p.pos+1]) {
+		p.pos++
+		for !p.eof() && isTOMLValueByte(p.peek()) {
+			p.pos++
+		}
+	}
+	token := p.src[start:p.pos]
+
+	if len(token) >= 8 && (token[2] == ':' || token[4] == '-') {
+		return p.parseDatetime(token)
+	}
+	value, ok := parseTOMLNumber(token)
+	if !ok {
+		return nil, p.errorf("invalid value %s", token)
+	}
+
+	return value, nil
+}
+
+func isTOMLValueByte(c byte) bool {
+	return isTOMLBareKeyByte(c) || c == '+' || c == '.' || c == ':'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// parseDatetime validates the datetime token, and normalizes it.
+func (p *tomlParser) parseDatetime(token string) (interface{}, error) {
+	s := token
+	if len(s) > 10 && (s[10] == ' ' || s[10] == 't') {
+		s = s[:10] + "T" + s[11:]
+	}
+	if strings.HasSuffix(s, "z") {
+		s = s[:len(s)-1] + "Z"
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02", "15:04:05.999999999"} {
+		_, err := time.Parse(layout, s)
+		if err == nil {
+			return tomlDatetime(s), nil
+		}
+	}
+
+	return nil, p.errorf("invalid datetime %s", token)
+}
+
+// parseTOMLNumber parses the integer or float s.
+func parseTOMLNumber(s string) (interface{}, bool) {
+	unsigned := strings.TrimLeft(s, "+-")
+	if len(s)-len(unsigned) > 1 {
+		return nil, false
+	}
+	switch unsigned {
+	case "inf":
+		if s[0] == '-' {
+			return math.Inf(-1), true
+		}
+		return math.Inf(1), true
+	case "nan":
+		return math.NaN(), true
+	}
+
+	if len(unsigned) > 2 && unsigned[0] == '0' && strings.ContainsRune("xob", rune(unsigned[1])) {
+		if s != unsigned {
+			return nil, false
+		}
+		base := map[byte]int{'x': 16, 'o': 8, 'b': 2}[unsigned[1]]
+		digits, ok := stripTOMLUnderscores(unsigned[2:], base)
+		if !ok {
+			return nil, false
+		}
+		n, err := strconv.ParseInt(digits, base, 64)
+		return n, err == nil
+	}
+
+	digits, ok := stripTOMLUnderscores(s, 10)
+	if !ok {
+		return nil, false
+	}
+	if strings.ContainsAny(unsigned, ".eE") {
+		// Dots must be surrounded by digits.
+		if i := strings.IndexByte(unsigned, '.'); i >= 0 && (i == 0 || i+1 == len(unsigned) || !isDigit(unsigned[i-1]) || !isDigit(unsigned[i+1])) {
+			return nil, false
+		}
+		if hasTOMLLeadingZero(unsigned) {
+			return nil, false
+		}
+		f, err := strconv.ParseFloat(digits, 64)
+		return f, err == nil
+	}
+	if hasTOMLLeadingZero(unsigned) {
+		return nil, false
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+
+	return n, err == nil
+}
+
+// hasTOMLLeadingZero reports whether the integer part of the unsigned decimal
+// number s has a leading zero.
+func hasTOMLLeadingZero(s string) bool {
+	return len(s) > 1 && s[0] == '0' && isDigit(s[1])
+}
+
+// stripTOMLUnderscores removes the underscores of s, which must each be
+// surrounded by digits of the given base.
+func stripTOMLUnderscores(s string, base int) (string, bool) {
+	if !strings.Contains(s, "_") {
+		return s, true
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '_' {
+			continue
+		}
+		if i == 0 || i+1 == len(s) || !isBaseDigit(s[i-1], base) || !isBaseDigit(s[i+1], base) {
+			return "", false
+		}
+	}
+
+	return strings.ReplaceAll(s, "_", ""), true
+}
+
+// isBaseDigit reports whether c is a digit of the given base, up to 16.
+func isBaseDigit(c byte, base int) bool {
+	if base <= 10 {
+		return c >= '0' && c < '0'+byte(base)
+	}
+
+	return isDigit(c) || (c >= 'a' && c < 'a'+byte(base-10)) || (c >= 'A' && c < 'A'+byte(base-10))
+}
@@ -0,0 +1,439 @@
+package structTags
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultXMLRootName is the name of the root element used when a
+// CustomMarshaller's XMLRootName is empty.
+const DefaultXMLRootName = "root"
+
+// xmlItemName names the elements of a root array, which need a name other than
+// the root's so that the document keeps a single root element.
+const xmlItemName = "item"
+
+// MarshalXMLDocument is like Marshal, but encodes obj as XML, where target tag
+// names become element names. The value itself is the root element, named
+// XMLRootName, and the output has no XML declaration. A root array becomes a
+// root element holding an item element for each of its elements, and a null
+// root becomes an empty root element.
+//
+// Objects become elements holding an element for each key, and the elements
+// of arrays are repeated under the name of the array. Null values are left
+// out. Struct fields also accept the following tag options:
+//
+//   - attr encodes the field as an attribute of the struct's element.
+//   - chardata encodes the field as the struct's character data.
+//   - cdata is like chardata, but wraps the text in a CDATA section.
+//
+// A name like "a>b>c" nests the field's element inside of a and b, which
+// consecutive fields share. Attributes and character data must be scalars.
+func (m *CustomMarshaller) MarshalXMLDocument(obj interface{}) ([]byte, error) {
+	root := m.XMLRootName
+	if root == "" {
+		root = DefaultXMLRootName
+	}
+	err := checkXMLName(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	err = m.MarshalFormat(&xmlFormat{m: m, b: &b, root: root}, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+var errXMLText = errors.New("XML attributes and character data must be scalars")
+
+// xmlFieldKind is where a struct field goes in its struct's XML element,
+// chosen with the attr, chardata and cdata tag options.
+type xmlFieldKind int
+
+const (
+	xmlElement xmlFieldKind = iota
+	xmlAttr
+	xmlCharData
+	xmlCData
+)
+
+func parseXMLFieldKind(options tagOptions) xmlFieldKind {
+	switch {
+	case options.Contains("attr"):
+		return xmlAttr
+	case options.Contains("chardata"):
+		return xmlCharData
+	case options.Contains("cdata"):
+		return xmlCData
+	}
+
+	return xmlElement
+}
+
+// checkXMLName reports names which can't be used for elements and attributes.
+func checkXMLName(name string) error {
+	for i, r := range name {
+		if unicode.IsLetter(r) || r == '_' || r == ':' {
+			continue
+		}
+		if i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.') {
+			continue
+		}
+		return fmt.Errorf("invalid XML name %q", name)
+	}
+	if name == "" {
+		return fmt.Errorf("invalid XML name %q", name)
+	}
+
+	return nil
+}
+
+// xmlScalarText formats the scalar v as XML text.
+func (m *CustomMarshaller) xmlScalarText(v reflect.Value) (string, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	case reflect.String:
+		return m.validUTF8(v.String())
+	}
+
+	return "", &UnsupportedTypeError{Type: v.Type()}
+}
+
+// xmlAttribute is an attribute of an element being written.
+type xmlAttribute struct {
+	name  string
+	value string
+}
+
+// xmlFrame is an element or array being written.
+type xmlFrame struct {
+	name string
+	// array is set for arrays, whose elements repeat the name of the array.
+	array bool
+	// key names the next element inside of an object.
+	key string
+	// parents holds the wrapper elements of the next element, and wrappers
+	// those which are open, for the fields of a struct.
+	parents  []string
+	wrappers []string
+	// rootArray is set for the root element written around a root array.
+	rootArray bool
+}
+
+// xmlFormat is the Format of MarshalXMLDocument. Structs aren't reported to it
+// as objects, but written by Struct, which applies the XML tag options.
+type xmlFormat struct {
+	m    *CustomMarshaller
+	b    *bytes.Buffer
+	root string
+	open []xmlFrame
+}
+
+// name returns the name of the next element, opening its wrapper elements.
+func (f *xmlFormat) name() (string, error) {
+	if len(f.open) == 0 {
+		return f.root, nil
+	}
+	top := &f.open[len(f.open)-1]
+	if top.array {
+		return top.name, nil
+	}
+	err := f.wrap(top.parents)
+	if err != nil {
+		return "", err
+	}
+
+	return top.key, nil
+}
+
+// beginElement writes the start tag of the next element.
+func (f *xmlFormat) beginElement(attrs []xmlAttribute) error {
+	name, err := f.name()
+	if err != nil {
+		return err
+	}
+	f.b.WriteByte('<')
+	f.b.WriteString(name)
+	for _, attr := range attrs {
+		f.b.WriteByte(' ')
+		f.b.WriteString(attr.name)
+		f.b.WriteString(`="`)
+		_ = xml.EscapeText(f.b, []byte(attr.value))
+		f.b.WriteByte('"')
+	}
+	f.b.WriteByte('>')
+	f.open = append(f.open, xmlFrame{name: name})
+	return nil
+}
+
+// wrap opens and closes wrapper elements, so that exactly parents are open
+// inside of the current element.
+func (f *xmlFormat) wrap(parents []string) error {
+	top := &f.open[len(f.open)-1]
+	common := 0
+	for common < len(top.wrappers) && common < len(parents) && top.wrappers[common] == parents[common] {
+		common++
+	}
+	for i := len(top.wrappers) - 1; i >= common; i-- {
+		f.endTag(top.wrappers[i])
+	}
+	top.wrappers = top.wrappers[:common]
+
+	for _, parent := range parents[common:] {
+		err := checkXMLName(parent)
+		if err != nil {
+			return err
+		}
+		f.b.WriteByte('<')
+		f.b.WriteString(parent)
+		f.b.WriteByte('>')
+		top.wrappers = append(top.wrappers, parent)
+	}
+
+	return nil
+}
+
+func (f *xmlFormat) endTag(name string) {
+	f.b.WriteString("</")
+	f.b.WriteString(name)
+	f.b.WriteByte('>')
+}
+
+// text writes character data inside of the current element, optionally as a
+// CDATA section.
+func (f *xmlFormat) text(s string, cdata bool) error {
+	err := f.wrap(nil)
+	if err != nil {
+		return err
+	}
+	if !cdata {
+		return xml.EscapeText(f.b, []byte(s))
+	}
+
+	// CDATA sections can't contain their own terminator, so it's split across
+	// two sections.
+	f.b.WriteString("<![CDATA[")
+	f.b.WriteString(strings.ReplaceAll(s, "]]>", "]]]]><![CDATA[>"))
+	f.b.WriteString("]]>")
+
+	return nil
+}
+
+func (f *xmlFormat) BeginObject() error {
+	return f.beginElement(nil)
+}
+
+func (f *xmlFormat) Key(key string) error {
+	err := checkXMLName(key)
+	if err != nil {
+		return err
+	}
+	top := &f.open[len(f.open)-1]
+	top.parents = nil
+	top.key = key
+	return nil
+}
+
+func (f *xmlFormat) EndObject() error {
+	err := f.wrap(nil)
+	if err != nil {
+		return err
+	}
+	f.endTag(f.open[len(f.open)-1].name)
+	f.open = f.open[:len(f.open)-1]
+	return nil
+}
+
+func (f *xmlFormat) BeginArray() error {
+	name := xmlItemName
+	if len(f.open) == 0 {
+		err := f.beginElement(nil)
+		if err != nil {
+			return err
+		}
+		f.open[0].rootArray = true
+	} else {
+		var err error
+		name, err = f.name()
+		if err != nil {
+			return err
+		}
+	}
+	f.open = append(f.open, xmlFrame{
+		name:  name,
+		array: true,
+	})
+	return nil
+}
+
+func (f *xmlFormat) EndArray() error {
+	f.open = f.open[:len(f.open)-1]
+	if len(f.open) == 1 && f.open[0].rootArray {
+		f.endTag(f.open[0].name)
+		f.open = f.open[:0]
+	}
+	return nil
+}
+
+// Scalar writes v as an element holding its text. Null values are left out,
+// apart from a null root, which is written as an empty root element.
+func (f *xmlFormat) Scalar(v reflect.Value) error {
+	if !v.IsValid() {
+		if len(f.open) == 0 {
+			f.b.WriteByte('<')
+			f.b.WriteString(f.root)
+			f.b.WriteString("/>")
+		}
+		return nil
+	}
+	s, err := f.m.xmlScalarText(v)
+	if err != nil {
+		return err
+	}
+
+	name, err := f.name()
+	if err != nil {
+		return err
+	}
+	f.b.WriteByte('<')
+	f.b.WriteString(name)
+	f.b.WriteByte('>')
+	err = xml.EscapeText(f.b, []byte(s))
+	if err != nil {
+		return err
+	}
+	f.endTag(name)
+
+	return nil
+}
+
+// xmlTextFormat is the Format used to walk attributes and character data,
+// which only accepts a single scalar.
+type xmlTextFormat struct {
+	m    *CustomMarshaller
+	text string
+	// ok is set once a scalar other than null is reported.
+	ok bool
+}
+
+func (f *xmlTextFormat) BeginObject() error {
+	return errXMLText
+}
+
+func (f *xmlTextFormat) Key(key string) error {
+	return errXMLText
+}
+
+func (f *xmlTextFormat) EndObject() error {
+	return errXMLText
+}
+
+func (f *xmlTextFormat) BeginArray() error {
+	return errXMLText
+}
+
+func (f *xmlTextFormat) EndArray() error {
+	return errXMLText
+}
+
+func (f *xmlTextFormat) Scalar(v reflect.Value) error {
+	if !v.IsValid() {
+		return nil
+	}
+	s, err := f.m.xmlScalarText(v)
+	if err != nil {
+		return err
+	}
+	f.text = s
+	f.ok = true
+	return nil
+}
+
+// Struct writes the struct as an element, placing its fields according to
+// their XML tag options.
+func (f *xmlFormat) Struct(s *StructWriter) error {
+	var attrs []xmlAttribute
+	for i := 0; i < s.NumField(); i++ {
+		field := s.Field(i)
+		if field.Omitted || parseXMLFieldKind(tagOptions(field.Options)) != xmlAttr {
+			continue
+		}
+		err := checkXMLName(field.Name)
+		if err != nil {
+			return s.FieldError(i, err)
+		}
+		text := xmlTextFormat{m: f.m}
+		err = s.WriteFieldTo(i, &text)
+		if err != nil {
+			return err
+		}
+		if text.ok {
+			attrs = append(attrs, xmlAttribute{
+				name:  field.Name,
+				value: text.text,
+			})
+		}
+	}
+	err := f.beginElement(attrs)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < s.NumField(); i++ {
+		field := s.Field(i)
+		kind := parseXMLFieldKind(tagOptions(field.Options))
+		if field.Omitted || kind == xmlAttr {
+			continue
+		}
+		if kind == xmlCharData || kind == xmlCData {
+			text := xmlTextFormat{m: f.m}
+			err = s.WriteFieldTo(i, &text)
+			if err != nil {
+				return err
+			}
+			if text.ok {
+				err = f.text(text.text, kind == xmlCData)
+				if err != nil {
+					return s.FieldError(i, err)
+				}
+			}
+			continue
+		}
+
+		names := strings.Split(field.Name, ">")
+		for _, name := range names {
+			err = checkXMLName(name)
+			if err != nil {
+				return s.FieldError(i, err)
+			}
+		}
+		// The wrapper elements are only opened along with the field's own
+		// element, so that null values are left out along with them.
+		top := &f.open[len(f.open)-1]
+		top.parents = names[:len(names)-1]
+		top.key = names[len(names)-1]
+		err = s.WriteField(i)
+		if err != nil {
+			return err
+		}
+	}
+
+	return f.EndObject()
+}
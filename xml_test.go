@@ -0,0 +1,191 @@
+package structTags
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+	"time"
+)
+
+type xmlItem struct {
+	SKU      string  `custom:"sku,attr"`
+	Quantity int     `custom:"quantity,attr,omitempty"`
+	Note     *string `custom:"note,attr"`
+	Name     string  `custom:",chardata"`
+}
+
+type xmlOrder struct {
+	ID       int       `custom:"id,attr"`
+	Ignored  string    `custom:"-"`
+	Customer string    `custom:"customer>name"`
+	Email    string    `custom:"customer>contact>email"`
+	Phone    *string   `custom:"customer>contact>phone"`
+	Items    []xmlItem `custom:"items>item"`
+	Tags     []string  `custom:"tag"`
+	Comment  string    `custom:"comment,cdata"`
+	Placed   time.Time `custom:"placed"`
+}
+
+type xmlWrapped struct {
+	Tags  []string          `custom:"tags>tag"`
+	Attrs map[string]string `custom:"meta>attrs"`
+	Count *int              `custom:"meta>count"`
+}
+
+type xmlInvalidAttr struct {
+	Values []int `custom:"values,attr"`
+}
+
+func TestCustomMarshaller_MarshalXMLDocument(t *testing.T) {
+	note := `"fragile" & <heavy>`
+
+	testCases := []struct {
+		Name           string
+		Input          any
+		Configure      func(m *CustomMarshaller)
+		ExpectedError  error
+		ExpectedOutput string
+	}{
+		{
+			Name: "attributes, character data and wrappers",
+			Input: xmlOrder{
+				ID:       7,
+				Ignored:  "ignored",
+				Customer: "Ada",
+				Email:    "ada@example.com",
+				Items: []xmlItem{
+					{SKU: "a-1", Quantity: 2, Note: &note, Name: "Widget"},
+					{SKU: "b-2", Name: "Gadget & co"},
+				},
+				Tags:    []string{"x", "y"},
+				Comment: "a ]]> b",
+				Placed:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			},
+			Configure: func(m *CustomMarshaller) {
+				m.XMLRootName = "order"
+			},
+			ExpectedOutput: `<order id="7">` +
+				`<customer><name>Ada</name><contact><email>ada@example.com</email></contact></customer>` +
+				`<items><item sku="a-1" quantity="2" note="&#34;fragile&#34; &amp; &lt;heavy&gt;">Widget</item><item sku="b-2">Gadget &amp; co</item></items>` +
+				`<tag>x</tag><tag>y</tag>` +
+				`<![CDATA[a ]]]]><![CDATA[> b]]>` +
+				`<placed>2024-01-02T03:04:05Z</placed>` +
+				`</order>`,
+		},
+		{
+			Name:           "nil values inside wrappers",
+			Input:          xmlWrapped{},
+			ExpectedOutput: `<root></root>`,
+		},
+		{
+			Name:  "nil values inside wrappers as empty",
+			Input: xmlWrapped{},
+			Configure: func(m *CustomMarshaller) {
+				m.NilSliceAsEmpty = true
+				m.NilMapAsEmpty = true
+			},
+			ExpectedOutput: `<root><tags></tags><meta><attrs></attrs></meta></root>`,
+		},
+		{
+			Name:  "untagged character data skipping untagged fields",
+			Input: xmlItem{SKU: "a-1", Name: "Widget"},
+			Configure: func(m *CustomMarshaller) {
+				m.UntaggedFields = SkipUntagged
+			},
+			ExpectedOutput: `<root sku="a-1">Widget</root>`,
+		},
+		{
+			Name:  "untagged character data failing on untagged fields",
+			Input: xmlItem{SKU: "a-1", Name: "Widget"},
+			Configure: func(m *CustomMarshaller) {
+				m.UntaggedFields = FailOnUntagged
+			},
+			ExpectedOutput: `<root sku="a-1">Widget</root>`,
+		},
+		{
+			Name:           "default root name",
+			Input:          scalarStruct{StringVar: "<a>", Float64Var: 1e21},
+			ExpectedOutput: `<root><string_var>&lt;a&gt;</string_var><int_var>0</int_var><int8_var>0</int8_var><int16_var>0</int16_var><int32_var>0</int32_var><int64_var>0</int64_var><uint_var>0</uint_var><uint8_var>0</uint8_var><uint16_var>0</uint16_var><uint32_var>0</uint32_var><uint64_var>0</uint64_var><float32_var>0</float32_var><float64_var>1e+21</float64_var><bool_var>false</bool_var></root>`,
+		},
+		{
+			Name:           "root arrays",
+			Input:          []parentStruct{{}, {}},
+			ExpectedOutput: `<root><item><child_struct_var><grand_child_struct_var><string_var></string_var></grand_child_struct_var></child_struct_var></item><item><child_struct_var><grand_child_struct_var><string_var></string_var></grand_child_struct_var></child_struct_var></item></root>`,
+		},
+		{
+			Name:           "nested root arrays",
+			Input:          [][]interface{}{{1, nil, "a"}, {}, {[]int{2}}},
+			Configure:      func(m *CustomMarshaller) { m.XMLRootName = "list" },
+			ExpectedOutput: `<list><item>1</item><item>a</item><item>2</item></list>`,
+		},
+		{
+			Name:           "empty root arrays",
+			Input:          []int{},
+			ExpectedOutput: `<root></root>`,
+		},
+		{
+			Name:           "maps and nested slices",
+			Input:          map[string]interface{}{"b": [][]int{{1, 2}, {3}}, "a": nil, "c": map[string]float64{"d": math.Inf(1)}},
+			ExpectedOutput: `<root><b>1</b><b>2</b><b>3</b><c><d>+Inf</d></c></root>`,
+		},
+		{
+			Name:           "marshalers",
+			Input:          map[string]interface{}{"json": spacedJSON{}, "text": upperText("up")},
+			ExpectedOutput: `<root><json><a>1</a><a>2</a></json><text>UP</text></root>`,
+		},
+		{
+			Name:           "nil",
+			Input:          nil,
+			ExpectedOutput: `<root/>`,
+		},
+		{
+			Name:           "nil pointer",
+			Input:          (*xmlOrder)(nil),
+			Configure:      func(m *CustomMarshaller) { m.XMLRootName = "order" },
+			ExpectedOutput: `<order/>`,
+		},
+		{
+			Name:          "invalid root name",
+			Input:         1,
+			Configure:     func(m *CustomMarshaller) { m.XMLRootName = "1st" },
+			ExpectedError: errors.New(`invalid XML name "1st"`),
+		},
+		{
+			Name:          "invalid element name",
+			Input:         map[string]int{"a b": 1},
+			ExpectedError: errors.New(`failed to marshal (root): invalid XML name "a b"`),
+		},
+		{
+			Name:          "non-scalar attribute",
+			Input:         xmlInvalidAttr{Values: []int{1}},
+			ExpectedError: errors.New("failed to marshal values: XML attributes and character data must be scalars"),
+		},
+		{
+			Name:  "rejected invalid UTF-8",
+			Input: map[string]string{"key": "a\xffb"},
+			Configure: func(m *CustomMarshaller) {
+				m.InvalidUTF8 = RejectInvalidUTF8
+			},
+			ExpectedError: errors.New(`failed to marshal ["key"]: string was not valid UTF-8: "a\xffb"`),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+			if testCase.Configure != nil {
+				testCase.Configure(m)
+			}
+			b, err := m.MarshalXMLDocument(testCase.Input)
+			assertError(t, testCase.ExpectedError, err)
+			assert.Equal(t, testCase.ExpectedOutput, string(b))
+		})
+	}
+}
+
+func TestCustomMarshaller_MarshalXMLDocument_JSONUnchanged(t *testing.T) {
+	b, err := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue).Marshal(xmlItem{SKU: "a", Name: "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"sku":"a","note":null,"Name":"b"}`+"\n", string(b))
+}
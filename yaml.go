@@ -0,0 +1,121 @@
+package structTags
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MarshalYAML is like Marshal, but encodes obj as a YAML document, keyed by
+// the same target tag names.
+func (m *CustomMarshaller) MarshalYAML(obj interface{}) ([]byte, error) {
+	f := &yamlFormat{m: m}
+	err := m.MarshalFormat(f, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(f.root)
+}
+
+// yamlFormat is the Format of MarshalYAML, which builds a tree of YAML nodes.
+type yamlFormat struct {
+	m    *CustomMarshaller
+	root *yaml.Node
+	// open holds the mappings and sequences being built.
+	open []*yaml.Node
+}
+
+// add appends n to the innermost open node, or makes it the root.
+func (f *yamlFormat) add(n *yaml.Node) {
+	if len(f.open) == 0 {
+		f.root = n
+		return
+	}
+	parent := f.open[len(f.open)-1]
+	parent.Content = append(parent.Content, n)
+}
+
+func (f *yamlFormat) begin(kind yaml.Kind, tag string) error {
+	n := &yaml.Node{
+		Kind: kind,
+		Tag:  tag,
+	}
+	f.add(n)
+	f.open = append(f.open, n)
+	return nil
+}
+
+func (f *yamlFormat) end() error {
+	f.open = f.open[:len(f.open)-1]
+	return nil
+}
+
+func (f *yamlFormat) BeginObject() error {
+	return f.begin(yaml.MappingNode, "!!map")
+}
+
+func (f *yamlFormat) Key(key string) error {
+	return f.Scalar(reflect.ValueOf(key))
+}
+
+func (f *yamlFormat) EndObject() error {
+	return f.end()
+}
+
+func (f *yamlFormat) BeginArray() error {
+	return f.begin(yaml.SequenceNode, "!!seq")
+}
+
+func (f *yamlFormat) EndArray() error {
+	return f.end()
+}
+
+// Scalar adds a scalar node. Strings are tagged, so that the encoder quotes
+// those which would otherwise read back as another type, e.g. "true".
+// Anything else is left untagged.
+func (f *yamlFormat) Scalar(v reflect.Value) error {
+	n := &yaml.Node{Kind: yaml.ScalarNode}
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		n.Value = "null"
+	case reflect.Bool:
+		n.Value = strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n.Value = strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n.Value = strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		n.Value = formatYAMLFloat(v.Float(), v.Type().Bits())
+	case reflect.String:
+		s, err := f.m.validUTF8(v.String())
+		if err != nil {
+			return err
+		}
+		n.Tag = "!!str"
+		n.Value = s
+	default:
+		return &UnsupportedTypeError{Type: v.Type()}
+	}
+	f.add(n)
+
+	return nil
+}
+
+// formatYAMLFloat formats f the way yaml.v3 does, which, unlike JSON, has
+// representations for infinities and NaN.
+func formatYAMLFloat(f float64, bits int) string {
+	switch {
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	case math.IsNaN(f):
+		return ".nan"
+	}
+
+	return strconv.FormatFloat(f, 'g', -1, bits)
+}
@@ -0,0 +1,106 @@
+package structTags
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestCustomMarshaller_MarshalYAML(t *testing.T) {
+	testCases := []struct {
+		Name           string
+		Input          any
+		Configure      func(m *CustomMarshaller)
+		ExpectedError  error
+		ExpectedOutput string
+	}{
+		{
+			Name: "scalar struct",
+			Input: scalarStruct{
+				StringVar:  "true",
+				IntVar:     -1,
+				Float64Var: 1.5,
+				BoolVar:    true,
+			},
+			ExpectedOutput: `string_var: "true"
+int_var: -1
+int8_var: 0
+int16_var: 0
+int32_var: 0
+int64_var: 0
+uint_var: 0
+uint8_var: 0
+uint16_var: 0
+uint32_var: 0
+uint64_var: 0
+float32_var: 0
+float64_var: 1.5
+bool_var: true
+`,
+		},
+		{
+			Name:  "nested structs and slices",
+			Input: []parentStruct{{ChildStructVar: childStruct{GrandChildStructVar: grandChildStruct{StringVar: "a\nb"}}}},
+			ExpectedOutput: `- child_struct_var:
+    grand_child_struct_var:
+        string_var: |-
+            a
+            b
+`,
+		},
+		{
+			Name:  "maps",
+			Input: map[string]interface{}{"b": []int{}, "a": nil, "c": map[string]int{}},
+			ExpectedOutput: `a: null
+b: []
+c: {}
+`,
+		},
+		{
+			Name:           "marshalers",
+			Input:          []interface{}{spacedJSON{}, upperText("up")},
+			ExpectedOutput: "- a:\n    - 1\n    - 2\n- UP\n",
+		},
+		{
+			Name:           "non-finite floats",
+			Input:          []float64{math.Inf(1), math.Inf(-1), math.NaN()},
+			ExpectedOutput: "- .inf\n- -.inf\n- .nan\n",
+		},
+		{
+			Name:           "invalid UTF-8",
+			Input:          "a\xffb",
+			ExpectedOutput: "a\uFFFDb\n",
+		},
+		{
+			Name:  "rejected invalid UTF-8",
+			Input: map[string]string{"key": "a\xffb"},
+			Configure: func(m *CustomMarshaller) {
+				m.InvalidUTF8 = RejectInvalidUTF8
+			},
+			ExpectedError: errors.New(`failed to marshal ["key"]: string was not valid UTF-8: "a\xffb"`),
+		},
+		{
+			Name:          "unsupported type",
+			Input:         make(chan int),
+			ExpectedError: errors.New("failed to marshal (root): unsupported type: chan int"),
+		},
+		{
+			Name:           "nil",
+			Input:          nil,
+			ExpectedOutput: "null\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			m := NewCustomMarshaller(targetCustomTag, ignoreTagWithValue)
+			if testCase.Configure != nil {
+				testCase.Configure(m)
+			}
+			b, err := m.MarshalYAML(testCase.Input)
+			assertError(t, testCase.ExpectedError, err)
+			assert.Equal(t, testCase.ExpectedOutput, string(b))
+		})
+	}
+}